@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	fixedBackoff       = "fixed"
+	exponentialBackoff = "exponential"
+	noBackoff          = "none"
+)
+
+// backoff determines how long to wait before re-executing
+// a program that failed.
+type backoff struct {
+	kind string
+	max  time.Duration
+
+	// jitter is the fraction of the interval by which it is
+	// randomly increased or decreased (e.g., 0.2 means ±20%).
+	// This prevents programs that failed at the same time
+	// from being retried at the same time.
+	jitter float64
+}
+
+func (o backoff) validate() error {
+	switch o.kind {
+	case fixedBackoff, exponentialBackoff, noBackoff:
+	default:
+		return fmt.Errorf("unknown backoff type: %q (supported types are: %s, %s, %s)",
+			o.kind, fixedBackoff, exponentialBackoff, noBackoff)
+	}
+
+	if o.max < 0 {
+		return fmt.Errorf("maximum retry interval cannot be negative (%s)", o.max)
+	}
+
+	if o.jitter < 0 || o.jitter > 1 {
+		return fmt.Errorf("jitter must be between 0 and 1 (%g)", o.jitter)
+	}
+
+	return nil
+}
+
+// interval returns the amount of time to wait before the
+// specified retry. retry starts at 1 for the first retry.
+func (o backoff) interval(initial time.Duration, retry int) time.Duration {
+	var d time.Duration
+
+	switch o.kind {
+	case noBackoff:
+		return 0
+	case exponentialBackoff:
+		d = initial
+
+		for i := 1; i < retry && d < math.MaxInt64/2; i++ {
+			d *= 2
+
+			if o.max > 0 && d >= o.max {
+				break
+			}
+		}
+	default:
+		d = initial
+	}
+
+	if o.jitter > 0 {
+		jittered := float64(d) * (1 + o.jitter*(2*rand.Float64()-1))
+		if jittered < math.MaxInt64 {
+			d = time.Duration(jittered)
+		}
+	}
+
+	if o.max > 0 && d > o.max {
+		d = o.max
+	}
+
+	return d
+}
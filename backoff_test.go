@@ -0,0 +1,107 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBackoffInterval(t *testing.T) {
+	tests := []struct {
+		name    string
+		backoff backoff
+		initial time.Duration
+		want    []time.Duration
+	}{
+		{
+			name:    "fixed",
+			backoff: backoff{kind: fixedBackoff},
+			initial: 10 * time.Second,
+			want:    []time.Duration{10 * time.Second, 10 * time.Second, 10 * time.Second},
+		},
+		{
+			name:    "fixed capped by max",
+			backoff: backoff{kind: fixedBackoff, max: 5 * time.Second},
+			initial: 10 * time.Second,
+			want:    []time.Duration{5 * time.Second, 5 * time.Second},
+		},
+		{
+			name:    "exponential",
+			backoff: backoff{kind: exponentialBackoff},
+			initial: time.Second,
+			want:    []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second},
+		},
+		{
+			name:    "exponential capped by max",
+			backoff: backoff{kind: exponentialBackoff, max: 5 * time.Second},
+			initial: time.Second,
+			want:    []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second},
+		},
+		{
+			name:    "none",
+			backoff: backoff{kind: noBackoff},
+			initial: 10 * time.Second,
+			want:    []time.Duration{0, 0},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []time.Duration
+
+			for retry := 1; retry <= len(test.want); retry++ {
+				got = append(got, test.backoff.interval(test.initial, retry))
+			}
+
+			if !slices.Equal(got, test.want) {
+				t.Fatalf("got %v - want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestBackoffIntervalDoesNotOverflow(t *testing.T) {
+	b := backoff{kind: exponentialBackoff}
+
+	for retry := 1; retry <= 100; retry++ {
+		d := b.interval(time.Second, retry)
+		if d <= 0 {
+			t.Fatalf("retry %d: interval overflowed to %s", retry, d)
+		}
+	}
+}
+
+func TestBackoffIntervalJitter(t *testing.T) {
+	b := backoff{kind: fixedBackoff, jitter: 0.2}
+
+	for i := 0; i < 100; i++ {
+		d := b.interval(10*time.Second, 1)
+		if d < 8*time.Second || d > 12*time.Second {
+			t.Fatalf("interval %s is not within 20%% of 10s", d)
+		}
+	}
+}
+
+func TestBackoffValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		backoff backoff
+		wantErr bool
+	}{
+		{name: "fixed", backoff: backoff{kind: fixedBackoff}},
+		{name: "exponential", backoff: backoff{kind: exponentialBackoff, max: time.Minute, jitter: 1}},
+		{name: "none", backoff: backoff{kind: noBackoff}},
+		{name: "unknown kind", backoff: backoff{kind: "linear"}, wantErr: true},
+		{name: "negative max", backoff: backoff{kind: fixedBackoff, max: -time.Second}, wantErr: true},
+		{name: "jitter too large", backoff: backoff{kind: fixedBackoff, jitter: 1.5}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.backoff.validate()
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v - want error: %t", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxShebangSize is the maximum number of bytes read from the
+// beginning of a program when checking its shebang.
+const maxShebangSize = 256
+
+// checkExes logs a warning for each program in o.exesDirs and
+// o.manifest that has an obvious problem. Otherwise, such
+// problems would go unnoticed until the program is supposed
+// to be executed.
+func (o *execCtl) checkExes() {
+	var exePaths []string
+
+	for _, exesDir := range o.exesDirs {
+		dirPath, err := resolveExesDir(exesDir)
+		if err != nil {
+			warnf("%s", err)
+
+			continue
+		}
+
+		dirExePaths, err := o.listDir(dirPath)
+		if err != nil {
+			warnf("%s", err)
+
+			continue
+		}
+
+		exePaths = append(exePaths, dirExePaths...)
+
+		if !isGlob(dirPath) {
+			_, err := loadDirConfig(dirPath)
+			if err != nil {
+				warnf("%s, so the directory will be skipped", err)
+			}
+		}
+	}
+
+	if o.manifest != "" {
+		entries, err := readManifest(o.manifest)
+		if err != nil {
+			warnf("failed to read manifest %q - %s", o.manifest, err)
+		}
+
+		for _, entry := range entries {
+			exePaths = append(exePaths, entry.exePath)
+		}
+	}
+
+	for _, exePath := range exePaths {
+		for _, problem := range o.exeProblems(exePath) {
+			warnf("[%s] %s", exePath, problem)
+		}
+	}
+}
+
+// exeProblems returns descriptions of the problems with the
+// program at exePath.
+func (o *execCtl) exeProblems(exePath string) []string {
+	info, err := os.Stat(exePath)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	name := filepath.Base(exePath)
+	interpreter := o.interpreters[filepath.Ext(name)]
+
+	var problems []string
+
+	if interpreter == nil && !isExecutable(exePath) {
+		problems = append(problems,
+			"file is not executable, so it will be ignored (try 'chmod +x')")
+	}
+
+	if o.strictPerms {
+		err := checkPerms(exePath)
+		if err != nil {
+			problems = append(problems, err.Error()+", so it will be skipped")
+		}
+	}
+
+	switch {
+	case info.Size() == 0:
+		problems = append(problems, "file is empty")
+	case interpreter == nil:
+		problem, hasProblem := checkShebang(exePath)
+		if hasProblem {
+			problems = append(problems, problem)
+		}
+	}
+
+	var markers []string
+
+	for _, marker := range append([]string{onSleepStr, onLockStr, onStartStr}, o.notificationStrs()...) {
+		if strings.Contains(name, marker) {
+			markers = append(markers, "'"+marker+"'")
+		}
+	}
+
+	if len(markers) > 1 {
+		problems = append(problems, fmt.Sprintf(
+			"name contains %s, so it will never be executed (only one is allowed)",
+			strings.Join(markers, " and ")))
+	}
+
+	// The screen is locked when lock programs are executed,
+	// so they would wait forever.
+	if strings.Contains(name, needsUnlockStr) && strings.Contains(name, onLockStr) {
+		problems = append(problems, fmt.Sprintf(
+			"name contains '%s' and '%s', so it will never be executed",
+			needsUnlockStr, onLockStr))
+	}
+
+	return problems
+}
+
+// notificationStrs returns the strings that identify the
+// programs executed for the additional notifications.
+func (o *execCtl) notificationStrs() []string {
+	var strs []string
+
+	for _, str := range o.notifications {
+		strs = append(strs, str)
+	}
+
+	return strs
+}
+
+// checkShebang returns a description of the problem with the
+// program's shebang line, if it has one. Programs without a
+// shebang (e.g., compiled programs) are not checked.
+func checkShebang(exePath string) (string, bool) {
+	f, err := os.Open(exePath)
+	if err != nil {
+		return err.Error(), true
+	}
+	defer f.Close()
+
+	// Peek returns an error if the file is smaller than
+	// maxShebangSize, which is expected.
+	start, _ := bufio.NewReaderSize(f, maxShebangSize).Peek(maxShebangSize)
+
+	line, _, _ := strings.Cut(string(start), "\n")
+
+	shebang, hasShebang := strings.CutPrefix(line, "#!")
+	if !hasShebang {
+		return "", false
+	}
+
+	fields := strings.Fields(shebang)
+	if len(fields) == 0 {
+		return "shebang does not specify an interpreter", true
+	}
+
+	_, err = os.Stat(fields[0])
+	if err != nil {
+		return fmt.Sprintf("shebang interpreter %q does not exist", fields[0]), true
+	}
+
+	return "", false
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// child tracks a program whose execRetry loop is active.
+// Its fields are protected by execCtl.childrenMu.
+type child struct {
+	exePath string
+	attempt int
+
+	// cmd is the program's process. It is nil while the
+	// program is waiting to be retried.
+	cmd     *exec.Cmd
+	started time.Time
+}
+
+// addChild registers a program whose execRetry loop has started.
+func (o *execCtl) addChild(exePath string) *child {
+	c := &child{
+		exePath: exePath,
+	}
+
+	o.childrenMu.Lock()
+	defer o.childrenMu.Unlock()
+
+	if o.children == nil {
+		o.children = make(map[string]*child)
+	}
+
+	o.children[exePath] = c
+
+	return c
+}
+
+// removeChild unregisters a program whose execRetry loop has
+// returned. A newer child for the same program is left alone.
+func (o *execCtl) removeChild(c *child) {
+	o.childrenMu.Lock()
+	defer o.childrenMu.Unlock()
+
+	if o.children[c.exePath] == c {
+		delete(o.children, c.exePath)
+	}
+}
+
+// setChildAttempt records the child's current execution attempt.
+// It is a no-op if c is nil.
+func (o *execCtl) setChildAttempt(c *child, attempt int) {
+	if c == nil {
+		return
+	}
+
+	o.childrenMu.Lock()
+	defer o.childrenMu.Unlock()
+
+	c.attempt = attempt
+}
+
+// setChildCmd records the child's running process, or that the
+// child is not running if cmd is nil. The time the process was
+// started is also recorded in o.lastStarts. It is a no-op if c
+// is nil.
+func (o *execCtl) setChildCmd(c *child, cmd *exec.Cmd) {
+	if c == nil {
+		return
+	}
+
+	o.childrenMu.Lock()
+	defer o.childrenMu.Unlock()
+
+	c.cmd = cmd
+
+	if cmd != nil {
+		c.started = time.Now()
+
+		if o.lastStarts == nil {
+			o.lastStarts = make(map[string]time.Time)
+		}
+
+		o.lastStarts[c.exePath] = c.started
+	}
+}
+
+// startedWithin returns true if the program was last started
+// less than d ago.
+func (o *execCtl) startedWithin(exePath string, d time.Duration) bool {
+	o.childrenMu.Lock()
+	defer o.childrenMu.Unlock()
+
+	started, ok := o.lastStarts[exePath]
+
+	return ok && time.Since(started) < d
+}
+
+// setSucceeded records that the program succeeded at the
+// current time.
+func (o *execCtl) setSucceeded(exePath string) {
+	o.childrenMu.Lock()
+	defer o.childrenMu.Unlock()
+
+	if o.lastSuccesses == nil {
+		o.lastSuccesses = make(map[string]time.Time)
+	}
+
+	o.lastSuccesses[exePath] = o.clock.Now()
+}
+
+// lastSucceeded returns the time the program last succeeded.
+// It returns false if the program has not succeeded since
+// waked started or, if its state is persisted, ever.
+func (o *execCtl) lastSucceeded(exePath string) (time.Time, bool) {
+	o.childrenMu.Lock()
+	defer o.childrenMu.Unlock()
+
+	succeeded, ok := o.lastSuccesses[exePath]
+
+	return succeeded, ok
+}
+
+// isChildActive returns true if the program's execRetry
+// loop is active.
+func (o *execCtl) isChildActive(exePath string) bool {
+	o.childrenMu.Lock()
+	defer o.childrenMu.Unlock()
+
+	_, active := o.children[exePath]
+
+	return active
+}
+
+// signalChildren sends sig to each running program.
+func (o *execCtl) signalChildren(sig syscall.Signal) {
+	o.childrenMu.Lock()
+	defer o.childrenMu.Unlock()
+
+	for _, c := range o.children {
+		if c.cmd == nil {
+			continue
+		}
+
+		err := c.cmd.Process.Signal(sig)
+		if err != nil {
+			warnf("[%s] failed to send %s - %s", c.exePath, sig, err)
+		}
+	}
+}
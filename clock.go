@@ -0,0 +1,26 @@
+package main
+
+import (
+	"time"
+)
+
+// clock provides the current time and timers. It allows the
+// retry loop's waits to be replaced, which means they can be
+// advanced deterministically rather than in real time.
+type clock interface {
+	Now() time.Time
+
+	// After has the same semantics as time.After.
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is a clock that uses the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
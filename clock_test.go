@@ -0,0 +1,50 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// fakeClock is a clock whose time only advances when After is
+// called. After returns a channel that is already ready, which
+// means waits return immediately. The durations passed to After
+// are recorded in waits.
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	waits []time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now: time.Date(2024, time.May, 1, 9, 0, 0, 0, time.UTC),
+	}
+}
+
+func (o *fakeClock) Now() time.Time {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.now
+}
+
+func (o *fakeClock) After(d time.Duration) <-chan time.Time {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.now = o.now.Add(d)
+	o.waits = append(o.waits, d)
+
+	c := make(chan time.Time, 1)
+	c <- o.now
+
+	return c
+}
+
+// Waits returns the durations passed to After so far.
+func (o *fakeClock) Waits() []time.Duration {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]time.Duration(nil), o.waits...)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// configProgramsKey is the name of the config file's table that
+// contains per-program settings.
+const configProgramsKey = "programs"
+
+// programsConfig is the format of the config file's
+// per-program settings.
+type programsConfig struct {
+	// Programs maps program names (e.g., "backup.sh") to
+	// settings, which use the same fields as sidecar files.
+	Programs map[string]*exeConfig `toml:"programs"`
+}
+
+// loadConfigFile parses the TOML config file at filePath. Each of
+// its top-level keys is the name of a flag in flags, whose value
+// is set to the key's value (e.g., 'timeout = "15m"' is equivalent
+// to '-timeout 15m'). Flags that were already specified on the
+// command line are left alone, which allows them to override the
+// config file.
+//
+// The per-program settings are returned, mapped by program name.
+func loadConfigFile(filePath string, flags *flag.FlagSet) (map[string]*exeConfig, error) {
+	var options map[string]any
+
+	_, err := toml.DecodeFile(filePath, &options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %q - %w", filePath, err)
+	}
+
+	alreadySet := make(map[string]struct{})
+
+	flags.Visit(func(f *flag.Flag) {
+		alreadySet[f.Name] = struct{}{}
+	})
+
+	var names []string
+
+	for name := range options {
+		if name != configProgramsKey {
+			names = append(names, name)
+		}
+	}
+
+	// Maps are unordered, and the order in which flags
+	// are set affects which error is reported first.
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := flags.Lookup(name)
+		if f == nil || name == configArg {
+			return nil, fmt.Errorf("config file %q: unknown option: %q", filePath, name)
+		}
+
+		if _, isSet := alreadySet[name]; isSet {
+			continue
+		}
+
+		values, err := configFlagValues(options[name])
+		if err != nil {
+			return nil, fmt.Errorf("config file %q: %s - %w", filePath, name, err)
+		}
+
+		_, isList := f.Value.(*stringList)
+		if len(values) != 1 && !isList {
+			return nil, fmt.Errorf("config file %q: %s cannot be a list", filePath, name)
+		}
+
+		for _, value := range values {
+			err := flags.Set(name, value)
+			if err != nil {
+				return nil, fmt.Errorf("config file %q: %s - %w", filePath, name, err)
+			}
+		}
+	}
+
+	var programs programsConfig
+
+	meta, err := toml.DecodeFile(filePath, &programs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %q %s table - %w",
+			filePath, configProgramsKey, err)
+	}
+
+	// Otherwise, a misspelled program setting would be
+	// silently ignored. The other options were already
+	// checked above.
+	var unknown []string
+
+	for _, key := range meta.Undecoded() {
+		if len(key) > 2 && key[0] == configProgramsKey {
+			unknown = append(unknown, key.String())
+		}
+	}
+
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("config file %q: unknown program settings: %s",
+			filePath, strings.Join(unknown, ", "))
+	}
+
+	for name, config := range programs.Programs {
+		err := config.validate()
+		if err != nil {
+			return nil, fmt.Errorf("config file %q: program %q: %w", filePath, name, err)
+		}
+	}
+
+	return programs.Programs, nil
+}
+
+// configFlagValues converts a config file value into the strings
+// accepted by flag.Value.Set. Lists are converted to one string
+// per element.
+func configFlagValues(v any) ([]string, error) {
+	switch value := v.(type) {
+	case string:
+		return []string{value}, nil
+	case bool:
+		return []string{strconv.FormatBool(value)}, nil
+	case int64:
+		return []string{strconv.FormatInt(value, 10)}, nil
+	case float64:
+		return []string{strconv.FormatFloat(value, 'f', -1, 64)}, nil
+	case []any:
+		var values []string
+
+		for _, elem := range value {
+			elemValues, err := configFlagValues(elem)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(elemValues) != 1 {
+				return nil, errors.New("lists cannot contain lists")
+			}
+
+			values = append(values, elemValues...)
+		}
+
+		return values, nil
+	default:
+		return nil, fmt.Errorf("unsupported value type: %T", v)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+// dependencyGraph tracks the programs executed for an event so that
+// a program can wait for the programs it depends on to finish.
+// Programs are identified by their names, which means a program can
+// depend on programs with the same name in several directories.
+type dependencyGraph struct {
+	nodes map[string][]*dependencyNode
+
+	// order contains the programs in an order in which each
+	// program comes after the programs it depends on.
+	order []*exeInfo
+
+	// cyclic contains the programs that are part of (or depend
+	// on) a dependency cycle. They are never executed.
+	cyclic []*exeInfo
+}
+
+type dependencyNode struct {
+	exe  *exeInfo
+	done chan struct{}
+	err  error
+}
+
+var (
+	// dependencyFailedErr is returned by dependencyGraph.wait
+	// when one of the program's dependencies failed.
+	dependencyFailedErr = fmt.Errorf("%w - dependency did not succeed", skippedErr)
+
+	// dependencyCycleErr is recorded for the programs that
+	// are part of (or depend on) a dependency cycle.
+	dependencyCycleErr = fmt.Errorf("%w - dependency cycle", skippedErr)
+)
+
+// newDependencyGraph returns a dependencyGraph for exes. Programs
+// that do not depend on each other keep their relative order.
+func newDependencyGraph(exes []*exeInfo) *dependencyGraph {
+	graph := &dependencyGraph{
+		nodes: make(map[string][]*dependencyNode),
+	}
+
+	for _, exe := range exes {
+		name := filepath.Base(exe.path)
+
+		graph.nodes[name] = append(graph.nodes[name], &dependencyNode{
+			exe:  exe,
+			done: make(chan struct{}),
+		})
+	}
+
+	// Each pass adds the programs whose dependencies have
+	// already been added. Whatever remains once a pass adds
+	// nothing is part of a cycle.
+	added := make(map[*exeInfo]bool)
+	remaining := exes
+
+	for len(remaining) > 0 {
+		var next []*exeInfo
+
+		for _, exe := range remaining {
+			if graph.dependenciesAdded(exe, added) {
+				graph.order = append(graph.order, exe)
+				added[exe] = true
+			} else {
+				next = append(next, exe)
+			}
+		}
+
+		if len(next) == len(remaining) {
+			graph.cyclic = next
+
+			break
+		}
+
+		remaining = next
+	}
+
+	for _, exe := range graph.cyclic {
+		graph.finish(exe, dependencyCycleErr)
+	}
+
+	return graph
+}
+
+func (o *dependencyGraph) dependenciesAdded(exe *exeInfo, added map[*exeInfo]bool) bool {
+	for _, name := range exe.dependsOn {
+		for _, node := range o.nodes[name] {
+			if !added[node.exe] {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// node returns the dependencyNode for exe.
+func (o *dependencyGraph) node(exe *exeInfo) *dependencyNode {
+	for _, node := range o.nodes[filepath.Base(exe.path)] {
+		if node.exe == exe {
+			return node
+		}
+	}
+
+	return nil
+}
+
+// finish records that exe finished executing. err is the
+// error returned by execRetry.
+func (o *dependencyGraph) finish(exe *exeInfo, err error) {
+	node := o.node(exe)
+	if node == nil {
+		return
+	}
+
+	node.err = err
+	close(node.done)
+}
+
+// wait waits for the programs that exe depends on to finish. It
+// returns an error that wraps dependencyFailedErr if any of them
+// failed. Dependencies that were skipped count as succeeded, and
+// dependencies that are not executed for the event are ignored.
+func (o *dependencyGraph) wait(ctx context.Context, exe *exeInfo) error {
+	for _, name := range exe.dependsOn {
+		nodes := o.nodes[name]
+		if len(nodes) == 0 {
+			debugf("[%s] dependency %q is not executed for this event, ignoring it",
+				exe.path, name)
+
+			continue
+		}
+
+		for _, node := range nodes {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-node.done:
+			}
+
+			if !dependencySucceeded(node.err) {
+				return fmt.Errorf("%w (%s)", dependencyFailedErr, node.exe.path)
+			}
+		}
+	}
+
+	return nil
+}
+
+// isWaiting returns true if any of the programs that exe depends
+// on have not finished yet.
+func (o *dependencyGraph) isWaiting(exe *exeInfo) bool {
+	for _, name := range exe.dependsOn {
+		for _, node := range o.nodes[name] {
+			select {
+			case <-node.done:
+			default:
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// dependencySucceeded returns true if err, which is the error
+// returned by a dependency, allows its dependents to execute.
+// A dependency that was skipped because of its own dependencies,
+// or that was deferred until a later event, did not succeed.
+func dependencySucceeded(err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, dependencyFailedErr),
+		errors.Is(err, dependencyCycleErr),
+		errors.Is(err, deferredErr):
+		return false
+	default:
+		return errors.Is(err, skippedErr)
+	}
+}
+
+// execAfterDependencies executes exe using execRetry once the
+// programs it depends on have succeeded.
+func (o *execCtl) execAfterDependencies(ctx context.Context, ev event, exe *exeInfo, graph *dependencyGraph, firstAttemptFn func()) error {
+	// A dependency that keeps failing may be retried
+	// indefinitely, so waiting for it must not block the
+	// programs that execSequential executes after exe.
+	if firstAttemptFn != nil && graph.isWaiting(exe) {
+		firstAttemptFn()
+		firstAttemptFn = nil
+	}
+
+	err := graph.wait(ctx, exe)
+	if err == nil {
+		err = o.execRetry(ctx, ev, exe, firstAttemptFn)
+	} else {
+		infof("[%s] not executing - %s", exe.path, err)
+	}
+
+	graph.finish(exe, err)
+
+	return err
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testExes returns an exeInfo for each name. deps maps a
+// program's name to the names of the programs it depends on.
+func testExes(names []string, deps map[string][]string) []*exeInfo {
+	var exes []*exeInfo
+
+	for _, name := range names {
+		exes = append(exes, &exeInfo{
+			path:      filepath.Join("/exes", name),
+			dependsOn: deps[name],
+		})
+	}
+
+	return exes
+}
+
+func exeNames(exes []*exeInfo) []string {
+	var names []string
+
+	for _, exe := range exes {
+		names = append(names, filepath.Base(exe.path))
+	}
+
+	return names
+}
+
+func TestDependencyGraphOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		names      []string
+		deps       map[string][]string
+		wantOrder  []string
+		wantCyclic []string
+	}{
+		{
+			name:      "no dependencies keeps order",
+			names:     []string{"a", "b", "c"},
+			wantOrder: []string{"a", "b", "c"},
+		},
+		{
+			name:      "dependency comes first",
+			names:     []string{"a", "b", "c"},
+			deps:      map[string][]string{"a": {"c"}},
+			wantOrder: []string{"b", "c", "a"},
+		},
+		{
+			name:      "chain",
+			names:     []string{"a", "b", "c"},
+			deps:      map[string][]string{"a": {"b"}, "b": {"c"}},
+			wantOrder: []string{"c", "b", "a"},
+		},
+		{
+			name:      "dependency not executed for event",
+			names:     []string{"a", "b"},
+			deps:      map[string][]string{"a": {"missing"}},
+			wantOrder: []string{"a", "b"},
+		},
+		{
+			name:       "cycle",
+			names:      []string{"a", "b", "c"},
+			deps:       map[string][]string{"a": {"b"}, "b": {"a"}},
+			wantOrder:  []string{"c"},
+			wantCyclic: []string{"a", "b"},
+		},
+		{
+			name:       "depends on cycle",
+			names:      []string{"a", "b", "c", "d"},
+			deps:       map[string][]string{"a": {"a"}, "b": {"a"}, "d": {"c"}},
+			wantOrder:  []string{"c", "d"},
+			wantCyclic: []string{"a", "b"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			graph := newDependencyGraph(testExes(test.names, test.deps))
+
+			if got := exeNames(graph.order); !slices.Equal(got, test.wantOrder) {
+				t.Fatalf("got order %q - want %q", got, test.wantOrder)
+			}
+
+			if got := exeNames(graph.cyclic); !slices.Equal(got, test.wantCyclic) {
+				t.Fatalf("got cyclic %q - want %q", got, test.wantCyclic)
+			}
+
+			for _, exe := range graph.cyclic {
+				err := graph.wait(context.Background(), &exeInfo{
+					path:      "/exes/dependent",
+					dependsOn: []string{filepath.Base(exe.path)},
+				})
+				if !errors.Is(err, dependencyFailedErr) {
+					t.Fatalf("got error %v for dependent of %s - want %v",
+						err, exe.path, dependencyFailedErr)
+				}
+			}
+		})
+	}
+}
+
+func TestDependencyGraphWait(t *testing.T) {
+	tests := []struct {
+		name    string
+		depErr  error
+		wantErr error
+	}{
+		{name: "succeeded"},
+		{name: "failed", depErr: errors.New("exit status 1"), wantErr: dependencyFailedErr},
+		{name: "stopped", depErr: stoppedErr, wantErr: dependencyFailedErr},
+		{name: "skipped", depErr: skippedErr},
+		{name: "deferred", depErr: deferredErr, wantErr: dependencyFailedErr},
+		{name: "dependency failed", depErr: dependencyFailedErr, wantErr: dependencyFailedErr},
+		{name: "dependency cycle", depErr: dependencyCycleErr, wantErr: dependencyFailedErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exes := testExes([]string{"dep", "dependent"}, map[string][]string{
+				"dependent": {"dep"},
+			})
+
+			graph := newDependencyGraph(exes)
+
+			if !graph.isWaiting(exes[1]) {
+				t.Fatal("dependent is not waiting before dependency finishes")
+			}
+
+			graph.finish(exes[0], test.depErr)
+
+			if graph.isWaiting(exes[1]) {
+				t.Fatal("dependent is waiting after dependency finished")
+			}
+
+			err := graph.wait(context.Background(), exes[1])
+			if test.wantErr == nil && err != nil {
+				t.Fatalf("got error %v - want nil", err)
+			} else if !errors.Is(err, test.wantErr) {
+				t.Fatalf("got error %v - want %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestDependencyGraphWaitCancelled(t *testing.T) {
+	exes := testExes([]string{"dep", "dependent"}, map[string][]string{
+		"dependent": {"dep"},
+	})
+
+	graph := newDependencyGraph(exes)
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	cancelFn()
+
+	err := graph.wait(ctx, exes[1])
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v - want %v", err, context.Canceled)
+	}
+}
+
+func TestExecSequentialDoesNotWaitForRetriedDependency(t *testing.T) {
+	ctl := newTestExecCtl(t, realClock{})
+
+	marker := filepath.Join(t.TempDir(), "c-executed")
+
+	exes := []*exeInfo{
+		writeTestExe(t, ctl, "1-a.sh", "exit 1"),
+		writeTestExe(t, ctl, "2-b.sh", "exit 0"),
+		writeTestExe(t, ctl, "3-c.sh", "touch "+marker),
+	}
+
+	// 1-a.sh is retried until ctx is done.
+	exes[0].retryInterval = time.Hour
+	exes[1].dependsOn = []string{"1-a.sh"}
+
+	captureLog(t)
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	ev := event{name: wakeNotification}
+	run := newExecRun(ev)
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ctl.execSequential(ctx, ev, exes, newDependencyGraph(exes), run)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("execSequential is blocked by the dependency")
+	}
+
+	cancelFn()
+	run.wg.Wait()
+
+	_, err := os.Stat(marker)
+	if err != nil {
+		t.Fatalf("unrelated program was not executed - %s", err)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// dirConfigFileName is the name of the TOML file in the root of
+// an executables directory that contains the default settings for
+// the executables in the directory.
+const dirConfigFileName = "_" + appName + ".toml"
+
+// dirConfig is the format of a directory's config file.
+type dirConfig struct {
+	// exeConfig's fields are the defaults for the executables
+	// in the directory. They are overridden by the config file's
+	// per-program settings and by sidecar files.
+	exeConfig
+
+	// Sequential, when set to true, causes the executables in
+	// the directory to be executed one at a time in order. It
+	// is equivalent to -sequential for the directory.
+	Sequential bool `toml:"sequential"`
+}
+
+// loadDirConfig parses the config file in dirPath. It returns nil
+// if the directory does not have a config file.
+func loadDirConfig(dirPath string) (*dirConfig, error) {
+	filePath := filepath.Join(dirPath, dirConfigFileName)
+
+	var config dirConfig
+
+	meta, err := toml.DecodeFile(filePath, &config)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to parse directory config file %q - %w", filePath, err)
+	}
+
+	// Otherwise, a misspelled setting would be silently
+	// ignored.
+	undecoded := meta.Undecoded()
+	if len(undecoded) > 0 {
+		var keys []string
+
+		for _, key := range undecoded {
+			keys = append(keys, key.String())
+		}
+
+		return nil, fmt.Errorf("directory config file %q: unknown settings: %s",
+			filePath, strings.Join(keys, ", "))
+	}
+
+	err = config.validate()
+	if err != nil {
+		return nil, fmt.Errorf("directory config file %q: %w", filePath, err)
+	}
+
+	return &config, nil
+}
+
+func isDirConfig(name string) bool {
+	return name == dirConfigFileName
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	// reloadEventName is the name of the event produced by
+	// notifyReloads.
+	reloadEventName = "SIGHUP"
+
+	// startEventName is the name of the event that occurs
+	// once when the program starts.
+	startEventName = "start"
+)
+
+// eventSource produces the events that cause programs to be
+// executed.
+type eventSource interface {
+	// Notify sends events to c until the source stops or ctx
+	// is done. After sending an event, the source waits for the
+	// event to be acknowledged before sending the next one.
+	//
+	// Notify may need to run on the main thread, in which case
+	// it blocks the calling goroutine until ctx is done.
+	Notify(ctx context.Context, c chan<- event) error
+}
+
+// simulatedEvents maps the event names accepted by
+// newSimulatedSource to notification names.
+var simulatedEvents = map[string]string{
+	"wake":   wakeNotification,
+	"sleep":  sleepNotification,
+	"lock":   lockNotification,
+	"unlock": unlockNotification,
+	"start":  startEventName,
+}
+
+func simulatedEventNames() []string {
+	var names []string
+
+	for name := range simulatedEvents {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// newSimulatedSource returns an eventSource that produces a single
+// event. eventName must be a key in simulatedEvents.
+func newSimulatedSource(eventName string) (*simulatedSource, error) {
+	notifName, ok := simulatedEvents[eventName]
+	if !ok {
+		return nil, fmt.Errorf("unknown event to simulate: %q", eventName)
+	}
+
+	return &simulatedSource{
+		notifName: notifName,
+	}, nil
+}
+
+// simulatedSource is an eventSource that produces a single event.
+type simulatedSource struct {
+	notifName string
+}
+
+// Notify sends the event to c, waits for it to be handled,
+// and then returns.
+func (o *simulatedSource) Notify(ctx context.Context, c chan<- event) error {
+	ev := newEvent(o.notifName)
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case c <- ev:
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-ev.handled():
+		return nil
+	}
+}
+
+// notifyReloads sends a reload event to c each time SIGHUP
+// is received until ctx is done.
+func notifyReloads(ctx context.Context, c chan<- event) {
+	hups := make(chan os.Signal, 1)
+	signal.Notify(hups, syscall.SIGHUP)
+	defer signal.Stop(hups)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hups:
+		}
+
+		ev := newEvent(reloadEventName)
+
+		select {
+		case <-ctx.Done():
+			return
+		case c <- ev:
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ev.handled():
+		}
+	}
+}
+
+// newEvent returns a new event for the specified notification
+// name that occurred at the current time.
+func newEvent(name string) event {
+	return event{
+		name: name,
+		time: time.Now(),
+		done: make(chan struct{}),
+	}
+}
+
+// event describes the notification that caused programs
+// to be executed.
+type event struct {
+	name       string
+	time       time.Time
+	sinceSleep time.Duration
+	done       chan struct{}
+}
+
+// ack marks the event as handled.
+func (o event) ack() {
+	if o.done != nil {
+		close(o.done)
+	}
+}
+
+// handled returns a channel that is closed once the event
+// has been handled.
+func (o event) handled() <-chan struct{} {
+	return o.done
+}
+
+// env returns the event's environment variables in the format
+// expected by exec.Cmd.Env.
+func (o event) env() []string {
+	env := []string{
+		"WAKED_EVENT=" + o.name,
+		"WAKED_TIME=" + o.time.Format(time.RFC3339),
+	}
+
+	if o.sinceSleep > 0 {
+		env = append(env, "WAKED_SINCE_SLEEP="+
+			strconv.FormatInt(int64(o.sinceSleep.Seconds()), 10))
+	}
+
+	return env
+}
+
+const (
+	acPowerSource      = "ac"
+	batteryPowerSource = "battery"
+)
+
+// eventPayload describes an event. It is written to programs'
+// stdin as JSON when -stdin-event is specified.
+type eventPayload struct {
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+
+	// SinceSleep is the number of seconds the computer
+	// was asleep, if known.
+	SinceSleep *int64 `json:"sinceSleep,omitempty"`
+
+	// ScreenLocked is nil if the lock state is unknown.
+	ScreenLocked *bool `json:"screenLocked"`
+
+	// PowerSource is empty if the power source is unknown.
+	PowerSource string `json:"powerSource,omitempty"`
+}
+
+// eventPayload returns the JSON-encoded eventPayload for ev.
+func (o *execCtl) eventPayload(ctx context.Context, ev event) ([]byte, error) {
+	payload := eventPayload{
+		Event: ev.name,
+		Time:  ev.time,
+	}
+
+	if ev.sinceSleep > 0 {
+		seconds := int64(ev.sinceSleep.Seconds())
+		payload.SinceSleep = &seconds
+	}
+
+	isLocked, err := o.isScreenLocked(ctx)
+	if err == nil {
+		payload.ScreenLocked = &isLocked
+	}
+
+	isOnAC, ok := isOnACPower()
+	switch {
+	case ok && isOnAC:
+		payload.PowerSource = acPowerSource
+	case ok:
+		payload.PowerSource = batteryPowerSource
+	}
+
+	return json.Marshal(payload)
+}
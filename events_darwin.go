@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/progrium/darwinkit/macos"
+	"github.com/progrium/darwinkit/macos/appkit"
+	"github.com/progrium/darwinkit/macos/foundation"
+	"github.com/progrium/darwinkit/objc"
+)
+
+// newEventSource returns an eventSource that also produces
+// events for the specified notifications.
+func newEventSource(notifNames []string) eventSource {
+	return &workspaceSource{
+		notifNames: notifNames,
+	}
+}
+
+// workspaceSource produces events from the shared workspace's
+// notification center and from the distributed notification
+// center, which posts screen lock events.
+type workspaceSource struct {
+	// notifNames are additional notifications to observe.
+	// It is unknown which center posts them, so they are
+	// observed on both.
+	notifNames []string
+}
+
+// launchTimeout is the amount of time the application has to
+// finish launching before an error is logged. Notifications are
+// not observed until it does.
+const launchTimeout = 10 * time.Second
+
+// observeHelp explains the most likely reason that notifications
+// cannot be observed.
+const observeHelp = appName + " must run in a user's login session (e.g., as a launch agent " +
+	"installed by -" + installArg + " rather than as a launch daemon), and must not be sandboxed"
+
+// Notify returns when ctx is done. It returns a non-nil error if
+// the notifications could not be observed. It must be called from
+// the main thread.
+func (o *workspaceSource) Notify(ctx context.Context, c chan<- event) error {
+	// Here we use the NSNotificationCenter via the shared workspace
+	// to receive NSWorkspaceDidWakeNotification and
+	// NSWorkspaceWillSleepNotification events.
+	//
+	// In order to do this, we need to execute the macOS app entrypoint
+	// code. If we do not do this, we never get events. Stackoverflow
+	// user Hans Passant notes:
+	//
+	//   "A console mode app for example, that won't work,
+	//   CFRunLoopRun() is crucial to allow the OS to make
+	//   callbacks."
+	//   - https://stackoverflow.com/questions/64009042/not-receiving-nsworkspacewillsleepnotification-from-notificationcenter-using-c-s#comment113219829_64009042
+	//
+	// Examples:
+	// https://forums.developer.apple.com/forums/thread/26430
+	// https://developer.apple.com/documentation/foundation/nsnotificationcenter/1411723-addobserverforname?language=objc
+	//
+	launched := make(chan struct{})
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-launched:
+		case <-time.After(launchTimeout):
+			errorf("application did not finish launching within %s, so no events will be received - %s",
+				launchTimeout, observeHelp)
+		}
+	}()
+
+	var observeErr error
+
+	macos.RunApp(func(app appkit.Application, _ *appkit.ApplicationDelegate) {
+		close(launched)
+
+		notifCenter := appkit.Workspace_SharedWorkspace().NotificationCenter()
+
+		// The screen lock notifications are not documented,
+		// and are only posted to the distributed notification
+		// center.
+		distNotifCenter := objc.Call[foundation.DistributedNotificationCenter](
+			foundation.DistributedNotificationCenterClass,
+			objc.Sel("defaultCenter"))
+
+		queue := foundation.OperationQueue_MainQueue()
+
+		// Stopping the application makes RunApp return.
+		// The run loop only checks if it was stopped after
+		// it handles an event, so post an empty one.
+		stopApp := func() {
+			queue.AddOperationWithBlock(func() {
+				app.Stop(nil)
+
+				app.PostEventAtStart(
+					appkit.Event_OtherEventWithTypeLocationModifierFlagsTimestampWindowNumberContextSubtypeData1Data2(
+						appkit.EventTypeApplicationDefined,
+						foundation.Point{},
+						0,
+						0,
+						0,
+						nil,
+						0,
+						0,
+						0),
+					true)
+			})
+		}
+
+		if notifCenter.IsNil() || distNotifCenter.IsNil() {
+			observeErr = errors.New("failed to get notification centers")
+
+			stopApp()
+
+			return
+		}
+
+		go func() {
+			<-ctx.Done()
+
+			stopApp()
+		}()
+
+		onNotif := func(notif foundation.Notification) {
+			ev := newEvent(string(notif.Name()))
+
+			select {
+			case <-ctx.Done():
+				return
+			case c <- ev:
+			}
+
+			// Blocking the main queue until the event
+			// is handled delays sleep until the sleep
+			// programs exit.
+			select {
+			case <-ctx.Done():
+			case <-ev.handled():
+			}
+		}
+
+		for _, name := range append([]string{wakeNotification, sleepNotification}, o.notifNames...) {
+			observer := notifCenter.AddObserverForNameObjectQueueUsingBlock(
+				foundation.NotificationName(name),
+				nil,
+				queue,
+				onNotif)
+			if observer.IsNil() {
+				observeErr = fmt.Errorf("failed to observe %s notifications", name)
+			}
+		}
+
+		for _, name := range append([]string{lockNotification, unlockNotification}, o.notifNames...) {
+			observer := distNotifCenter.AddObserverForNameObjectQueueUsingBlock(
+				foundation.NotificationName(name),
+				nil,
+				queue,
+				onNotif)
+			if observer.IsNil() {
+				observeErr = fmt.Errorf("failed to observe distributed %s notifications", name)
+			}
+		}
+
+		// Otherwise, waked would appear to be running
+		// without ever executing anything.
+		if observeErr != nil {
+			stopApp()
+		}
+	})
+
+	if observeErr != nil {
+		return fmt.Errorf("%w - %s", observeErr, observeHelp)
+	}
+
+	return nil
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	logindDest         = "org.freedesktop.login1"
+	logindPath         = "/org/freedesktop/login1"
+	logindManagerIface = "org.freedesktop.login1.Manager"
+
+	dbusPropertiesIface = "org.freedesktop.DBus.Properties"
+)
+
+// newEventSource returns an eventSource that produces sleep, wake,
+// lock, and unlock events using systemd-logind. Additional
+// notifications are not supported.
+func newEventSource(notifNames []string) eventSource {
+	if len(notifNames) > 0 {
+		warnf("additional notifications are only supported on macOS - ignoring: %q",
+			notifNames)
+	}
+
+	return &logindSource{}
+}
+
+// logindSource produces sleep and wake events from systemd-logind's
+// PrepareForSleep signal, and lock and unlock events from changes
+// to the LockedHint property of the session returned by
+// logindSession.
+//
+// logind only waits for programs to handle the signal if they
+// hold a "delay" inhibitor lock. The lock is released once the
+// sleep programs exit (or logind's InhibitDelayMaxSec elapses),
+// and is taken again when the computer wakes.
+type logindSource struct {
+	conn      *dbus.Conn
+	inhibitor *os.File
+
+	// isLocked is the session's last known LockedHint.
+	isLocked bool
+}
+
+// Notify returns when ctx is done or the system bus
+// connection is closed.
+func (o *logindSource) Notify(ctx context.Context, c chan<- event) error {
+	var err error
+
+	o.conn, err = dbus.ConnectSystemBus(dbus.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to connect to system bus - %w", err)
+	}
+	defer o.conn.Close()
+
+	err = o.conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(logindPath),
+		dbus.WithMatchInterface(logindManagerIface),
+		dbus.WithMatchMember("PrepareForSleep"))
+	if err != nil {
+		return fmt.Errorf("failed to add PrepareForSleep signal match - %w", err)
+	}
+
+	// Sleep and wake events do not need a session (e.g.,
+	// on a server), so this is not fatal.
+	err = o.watchLockedHint()
+	if err != nil {
+		warnf("lock and unlock events are not available - %s", err)
+	}
+
+	signals := make(chan *dbus.Signal, 1)
+	o.conn.Signal(signals)
+
+	err = o.inhibit()
+	if err != nil {
+		return err
+	}
+	defer o.uninhibit()
+
+	for {
+		var signal *dbus.Signal
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case signal = <-signals:
+		}
+
+		if signal == nil {
+			return fmt.Errorf("system bus connection closed")
+		}
+
+		ev, ok := o.signalEvent(signal)
+		if !ok {
+			continue
+		}
+
+		isSleep := ev.name == sleepNotification
+
+		if ev.name == wakeNotification {
+			err := o.inhibit()
+			if err != nil {
+				warnf("%s", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case c <- ev:
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ev.handled():
+		}
+
+		if isSleep {
+			o.uninhibit()
+		}
+	}
+}
+
+// watchLockedHint subscribes to changes to the LockedHint property
+// of the session returned by logindSession and reads its current
+// value.
+func (o *logindSource) watchLockedHint() error {
+	sessionPath, err := logindSession(o.conn)
+	if err != nil {
+		return err
+	}
+
+	err = o.conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(sessionPath),
+		dbus.WithMatchInterface(dbusPropertiesIface),
+		dbus.WithMatchMember("PropertiesChanged"),
+		dbus.WithMatchArg(0, logindSessionIface))
+	if err != nil {
+		return fmt.Errorf("failed to add session PropertiesChanged signal match - %w", err)
+	}
+
+	// Otherwise, the first change would be compared
+	// to the wrong value.
+	o.isLocked, err = sessionLockedHint(o.conn, sessionPath)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// signalEvent returns the event for signal. It returns false if
+// the signal does not produce an event (e.g., because a session
+// property other than LockedHint changed).
+func (o *logindSource) signalEvent(signal *dbus.Signal) (event, bool) {
+	switch signal.Name {
+	case logindManagerIface + ".PrepareForSleep":
+		if len(signal.Body) == 0 {
+			return event{}, false
+		}
+
+		isSleep, ok := signal.Body[0].(bool)
+		if !ok {
+			return event{}, false
+		}
+
+		if isSleep {
+			return newEvent(sleepNotification), true
+		}
+
+		return newEvent(wakeNotification), true
+	case dbusPropertiesIface + ".PropertiesChanged":
+		if len(signal.Body) < 2 {
+			return event{}, false
+		}
+
+		changed, ok := signal.Body[1].(map[string]dbus.Variant)
+		if !ok {
+			return event{}, false
+		}
+
+		lockedHint, ok := changed["LockedHint"]
+		if !ok {
+			return event{}, false
+		}
+
+		isLocked, ok := lockedHint.Value().(bool)
+		if !ok || isLocked == o.isLocked {
+			return event{}, false
+		}
+
+		o.isLocked = isLocked
+
+		if isLocked {
+			return newEvent(lockNotification), true
+		}
+
+		return newEvent(unlockNotification), true
+	default:
+		return event{}, false
+	}
+}
+
+// inhibit takes a logind delay inhibitor lock for sleep
+// if one is not already held.
+func (o *logindSource) inhibit() error {
+	if o.inhibitor != nil {
+		return nil
+	}
+
+	var fd dbus.UnixFD
+
+	err := o.conn.Object(logindDest, logindPath).Call(
+		logindManagerIface+".Inhibit",
+		0,
+		"sleep",
+		appName,
+		"Executing sleep programs",
+		"delay").Store(&fd)
+	if err != nil {
+		return fmt.Errorf("failed to take sleep inhibitor lock - %w", err)
+	}
+
+	o.inhibitor = os.NewFile(uintptr(fd), "inhibitor")
+
+	return nil
+}
+
+// uninhibit releases the inhibitor lock, which allows
+// the computer to sleep.
+func (o *logindSource) uninhibit() {
+	if o.inhibitor != nil {
+		o.inhibitor.Close()
+		o.inhibitor = nil
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+func TestLogindSourceSignalEvent(t *testing.T) {
+	prepareForSleep := func(isSleep bool) *dbus.Signal {
+		return &dbus.Signal{
+			Name: logindManagerIface + ".PrepareForSleep",
+			Body: []any{isSleep},
+		}
+	}
+
+	lockedHint := func(isLocked bool) *dbus.Signal {
+		return &dbus.Signal{
+			Name: dbusPropertiesIface + ".PropertiesChanged",
+			Body: []any{
+				logindSessionIface,
+				map[string]dbus.Variant{"LockedHint": dbus.MakeVariant(isLocked)},
+				[]string{},
+			},
+		}
+	}
+
+	otherProperty := &dbus.Signal{
+		Name: dbusPropertiesIface + ".PropertiesChanged",
+		Body: []any{
+			logindSessionIface,
+			map[string]dbus.Variant{"IdleHint": dbus.MakeVariant(true)},
+			[]string{},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		signals  []*dbus.Signal
+		wantEvts []string
+	}{
+		{
+			name:     "sleep and wake",
+			signals:  []*dbus.Signal{prepareForSleep(true), prepareForSleep(false)},
+			wantEvts: []string{sleepNotification, wakeNotification},
+		},
+		{
+			name:     "lock and unlock",
+			signals:  []*dbus.Signal{lockedHint(true), lockedHint(false)},
+			wantEvts: []string{lockNotification, unlockNotification},
+		},
+		{
+			name:     "unchanged lock state",
+			signals:  []*dbus.Signal{lockedHint(false), lockedHint(true), lockedHint(true)},
+			wantEvts: []string{lockNotification},
+		},
+		{
+			name: "ignored signals",
+			signals: []*dbus.Signal{
+				otherProperty,
+				{Name: logindManagerIface + ".PrepareForSleep"},
+				{Name: logindManagerIface + ".PrepareForShutdown", Body: []any{true}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var source logindSource
+			var got []string
+
+			for _, signal := range test.signals {
+				ev, ok := source.signalEvent(signal)
+				if ok {
+					got = append(got, ev.name)
+				}
+			}
+
+			if !slices.Equal(got, test.wantEvts) {
+				t.Fatalf("got events %q - want %q", got, test.wantEvts)
+			}
+		})
+	}
+}
@@ -0,0 +1,624 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// findExes returns the executables in o.exesDirs and o.manifest
+// that should be executed for the specified event, ordered by
+// priority. Executables with the same name in different directories
+// are all returned. Within a priority, the manifest's executables are
+// returned last in the order they are listed.
+//
+// A non-nil error is returned if any of the directories or the
+// manifest could not be read. The executables found in the others
+// are returned regardless.
+func (o *execCtl) findExes(ev event) ([]*exeInfo, error) {
+	var exes []*exeInfo
+	var errs []error
+
+	for _, exesDir := range o.exesDirs {
+		dirExes, err := o.findExesInDir(ev, exesDir)
+		if err != nil {
+			errs = append(errs, err)
+
+			continue
+		}
+
+		exes = append(exes, dirExes...)
+	}
+
+	if o.manifest != "" {
+		manifestExes, err := o.findExesInManifest(ev)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			exes = append(exes, manifestExes...)
+		}
+	}
+
+	// Executables with the same priority stay in the
+	// order described above.
+	slices.SortStableFunc(exes, func(a *exeInfo, b *exeInfo) int {
+		return cmp.Compare(a.priority, b.priority)
+	})
+
+	return exes, errors.Join(errs...)
+}
+
+// dirRetryInterval is the amount of time between attempts to
+// read the executables directories when o.dirRetry is non-zero.
+const dirRetryInterval = time.Second
+
+// findExesWithRetry is like findExes, except that it keeps trying
+// to read the directories and manifest for up to o.dirRetry if any
+// of them could not be read. This prevents a missed run when a
+// directory is briefly unavailable after waking (e.g., because it is
+// on a network share that has not been remounted yet).
+func (o *execCtl) findExesWithRetry(ev event) ([]*exeInfo, error) {
+	exes, err := o.findExes(ev)
+	if err == nil || o.dirRetry <= 0 {
+		return exes, err
+	}
+
+	warnf("failed to find executables, will retry for up to %s - %s", o.dirRetry, err)
+
+	deadline := o.clock.Now().Add(o.dirRetry)
+
+	for {
+		remaining := deadline.Sub(o.clock.Now())
+		if remaining <= 0 {
+			return exes, err
+		}
+
+		select {
+		case <-o.ctx.Done():
+			return exes, err
+		case <-o.clock.After(min(dirRetryInterval, remaining)):
+		}
+
+		exes, err = o.findExes(ev)
+		if err == nil {
+			infof("found executables after retrying")
+
+			return exes, nil
+		}
+	}
+}
+
+func (o *execCtl) findExesInDir(ev event, exesDir string) ([]*exeInfo, error) {
+	dirPath, err := resolveExesDir(exesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	exePaths, err := o.listDir(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var config *dirConfig
+
+	// A glob pattern is not a directory, so it cannot
+	// contain a config file.
+	if !isGlob(dirPath) {
+		config, err = loadDirConfig(dirPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var dirDefaults *exeConfig
+
+	if config != nil {
+		dirDefaults = &config.exeConfig
+	}
+
+	var exes []*exeInfo
+
+	for _, exePath := range exePaths {
+		exe, ok := o.exeForEvent(ev, exePath, dirDefaults)
+		if !ok {
+			continue
+		}
+
+		if config != nil && config.Sequential {
+			exe.sequentialDir = exesDir
+		}
+
+		exes = append(exes, exe)
+	}
+
+	return exes, nil
+}
+
+// resolveExesDir returns the directory that exesDir points to if
+// it is a symbolic link. Glob patterns are returned as is. The
+// result is used for the directory's files, ignore file, and config
+// file. Resolving the link once per event means that flipping it
+// (e.g., to deploy a new release) never mixes them up between
+// different directories.
+func resolveExesDir(exesDir string) (string, error) {
+	if isGlob(exesDir) {
+		return exesDir, nil
+	}
+
+	resolved, err := resolveSymlink(exesDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve executables directory %q - %w",
+			exesDir, err)
+	}
+
+	return resolved, nil
+}
+
+// listDir returns the paths of the files in exesDir that are not
+// sidecar files and are not ignored. The files may not be
+// executable. If exesDir is a glob pattern, the files that
+// currently match it are returned instead. exesDir must already
+// be resolved by resolveExesDir.
+func (o *execCtl) listDir(exesDir string) ([]string, error) {
+	var filePaths []string
+	var err error
+
+	switch {
+	case isGlob(exesDir):
+		filePaths, err = globFiles(exesDir)
+	case o.recursive:
+		filePaths, err = walkFiles(exesDir, o.followSymlinks)
+	default:
+		filePaths, err = readDirFiles(exesDir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read executables directory %q - %w",
+			exesDir, err)
+	}
+
+	var ignoreRules ignoreRules
+
+	// A glob pattern is not a directory, so it cannot
+	// contain an ignore file.
+	if !isGlob(exesDir) {
+		ignoreRules = loadIgnoreFile(exesDir)
+	}
+
+	var exePaths []string
+
+	for _, filePath := range filePaths {
+		name := filepath.Base(filePath)
+
+		if isSidecar(name) || isDirConfig(name) || o.isIgnored(name) {
+			continue
+		}
+
+		relPath, err := filepath.Rel(exesDir, filePath)
+		if err == nil && ignoreRules.isIgnored(relPath) {
+			continue
+		}
+
+		exePaths = append(exePaths, filePath)
+	}
+
+	// Files with the same name in different subdirectories
+	// stay in the order they were walked.
+	slices.SortStableFunc(exePaths, func(a string, b string) int {
+		return compareNames(filepath.Base(a), filepath.Base(b))
+	})
+
+	return exePaths, nil
+}
+
+// compareNames compares file names by their leading number
+// (e.g., "2" in "2-backup") and then lexically, which means
+// "2-backup" sorts before "10-mount". Names that begin with a
+// number sort before those that do not.
+func compareNames(a string, b string) int {
+	aNum, aHasNum := leadingNumber(a)
+	bNum, bHasNum := leadingNumber(b)
+
+	switch {
+	case aHasNum && bHasNum && aNum != bNum:
+		return cmp.Compare(aNum, bNum)
+	case aHasNum && !bHasNum:
+		return -1
+	case !aHasNum && bHasNum:
+		return 1
+	}
+
+	return strings.Compare(a, b)
+}
+
+// leadingNumber parses the digits at the beginning of name.
+// It returns false if name does not begin with a number.
+func leadingNumber(name string) (uint64, bool) {
+	end := strings.IndexFunc(name, func(r rune) bool {
+		return r < '0' || r > '9'
+	})
+	if end < 0 {
+		end = len(name)
+	}
+
+	num, err := strconv.ParseUint(name[:end], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return num, true
+}
+
+// exeForEvent returns the exeInfo for exePath. dirDefaults, if
+// non-nil, are the settings from the config file of the directory
+// containing the executable. It returns false if the executable
+// should not be executed for the event.
+func (o *execCtl) exeForEvent(ev event, exePath string, dirDefaults *exeConfig) (*exeInfo, bool) {
+	name := filepath.Base(exePath)
+
+	if !o.isSelected(name) {
+		debugf("[%s] not executing - excluded by -%s or -%s", exePath, onlyArg, skipArg)
+
+		return nil, false
+	}
+
+	isSleep := ev.name == sleepNotification
+
+	if strings.Contains(name, onSleepStr) != isSleep {
+		return nil, false
+	}
+
+	if strings.Contains(name, onLockStr) != (ev.name == lockNotification) {
+		return nil, false
+	}
+
+	if strings.Contains(name, onStartStr) != (ev.name == startEventName) {
+		return nil, false
+	}
+
+	for notifName, nameStr := range o.notifications {
+		if strings.Contains(name, nameStr) != (ev.name == notifName) {
+			return nil, false
+		}
+	}
+
+	interpreter := o.interpreters[filepath.Ext(name)]
+
+	if interpreter == nil && !isExecutable(exePath) {
+		return nil, false
+	}
+
+	if o.strictPerms {
+		err := checkPerms(exePath)
+		if err != nil {
+			warnf("[%s] skipping executable - %s", exePath, err)
+
+			return nil, false
+		}
+	}
+
+	exe, err := newExeInfo(exePath, o.defaults, dirDefaults, o.programConfigs[name])
+	if err != nil {
+		warnf("[%s] skipping executable - %s", exePath, err)
+
+		return nil, false
+	}
+
+	if o.unlockOnly && o.isWakeProgram(name) {
+		exe.needsUnlock = true
+	}
+
+	// Only the programs that wait for the screen to be
+	// unlocked are executed when it is unlocked.
+	if ev.name == unlockNotification && !exe.needsUnlock {
+		return nil, false
+	}
+
+	// This is checked after the event filters so that it is
+	// not logged for programs that are not executed for the
+	// event anyway.
+	if o.minAge > 0 {
+		info, err := os.Stat(exePath)
+		if err == nil && o.clock.Now().Sub(info.ModTime()) < o.minAge {
+			infof("[%s] not executing - modified less than %s ago, it may still be being written (-%s)",
+				exePath, o.minAge, minAgeArg)
+
+			return nil, false
+		}
+	}
+
+	exe.interpreter = interpreter
+
+	if isSleep {
+		// Sleep is delayed until the sleep programs
+		// exit, so they are always stopped.
+		exe.timeout = o.sleepTimeout
+		exe.warnOnTimeout = false
+		exe.waitFor = nil
+	}
+
+	return exe, true
+}
+
+// isWakeProgram returns true if the file name does not identify
+// it as a program for an event other than wake (e.g., sleep).
+func (o *execCtl) isWakeProgram(name string) bool {
+	for _, marker := range append([]string{onSleepStr, onLockStr, onStartStr}, o.notificationStrs()...) {
+		if strings.Contains(name, marker) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// tempFilePatterns match the names of files commonly created
+// by text editors.
+var tempFilePatterns = []string{
+	"*~",
+	"#*#",
+	"*.swp",
+	"*.swo",
+	"*.tmp",
+}
+
+// isIgnored returns true if the file name is hidden, is a text
+// editor's temporary file, or matches one of o.ignore.
+func (o *execCtl) isIgnored(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return true
+	}
+
+	for _, patterns := range [][]string{tempFilePatterns, o.ignore} {
+		for _, pattern := range patterns {
+			// Patterns are validated by execCtl.validate.
+			matched, _ := filepath.Match(pattern, name)
+			if matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isSelected returns true if the file name matches one of o.only
+// (when specified) and does not match any of o.skip.
+func (o *execCtl) isSelected(name string) bool {
+	if len(o.only) > 0 && !matchesAny(o.only, name) {
+		return false
+	}
+
+	return !matchesAny(o.skip, name)
+}
+
+// matchesAny returns true if name matches one of patterns.
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		// Patterns are validated by parseNamePatterns.
+		matched, _ := filepath.Match(pattern, name)
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseNamePatterns splits the comma-separated values of the
+// flag named argName into glob patterns and validates them.
+func parseNamePatterns(argName string, values []string) ([]string, error) {
+	var patterns []string
+
+	for _, value := range values {
+		for _, pattern := range strings.Split(value, ",") {
+			pattern = strings.TrimSpace(pattern)
+			if pattern == "" {
+				continue
+			}
+
+			_, err := filepath.Match(pattern, "")
+			if err != nil {
+				return nil, fmt.Errorf("invalid -%s pattern: %q - %w", argName, pattern, err)
+			}
+
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	return patterns, nil
+}
+
+// isExecutable returns true if filePath has at least one
+// executable permission bit set.
+func isExecutable(filePath string) bool {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return false
+	}
+
+	return info.Mode().IsRegular() && info.Mode().Perm()&0o111 != 0
+}
+
+// checkPerms returns a non-nil error if the file at filePath or
+// its directory can be written to by users other than its owner.
+func checkPerms(filePath string) error {
+	for _, checkPath := range []string{filePath, filepath.Dir(filePath)} {
+		info, err := os.Stat(checkPath)
+		if err != nil {
+			return err
+		}
+
+		switch perm := info.Mode().Perm(); {
+		case perm&0o002 != 0:
+			return fmt.Errorf("%q is world-writable (-%s)", checkPath, strictPermsArg)
+		case perm&0o020 != 0:
+			return fmt.Errorf("%q is group-writable (-%s)", checkPath, strictPermsArg)
+		}
+	}
+
+	return nil
+}
+
+// resolveSymlink returns the path that filePath points to if it
+// is a symbolic link. Otherwise, filePath is returned. Unlike
+// filepath.EvalSymlinks, symbolic links in filePath's parent
+// directories are left alone (e.g., "/tmp" on macOS).
+func resolveSymlink(filePath string) (string, error) {
+	// Each iteration resolves one link in a chain of links.
+	for range 255 {
+		info, err := os.Lstat(filePath)
+		if err != nil {
+			return "", err
+		}
+
+		if info.Mode()&fs.ModeSymlink == 0 {
+			return filePath, nil
+		}
+
+		target, err := os.Readlink(filePath)
+		if err != nil {
+			return "", err
+		}
+
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(filePath), target)
+		}
+
+		filePath = target
+	}
+
+	return "", errors.New("too many levels of symbolic links")
+}
+
+// isGlob returns true if filePath contains any of the
+// metacharacters supported by filepath.Match.
+func isGlob(filePath string) bool {
+	return strings.ContainsAny(filePath, "*?[")
+}
+
+// globFiles returns the paths of the non-directory files that
+// match pattern in lexical order.
+func globFiles(pattern string) ([]string, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var filePaths []string
+
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		filePaths = append(filePaths, match)
+	}
+
+	return filePaths, nil
+}
+
+// readDirFiles returns the paths of the non-directory files
+// in dirPath in lexical order.
+func readDirFiles(dirPath string) ([]string, error) {
+	infos, err := os.ReadDir(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var filePaths []string
+
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+
+		filePaths = append(filePaths, filepath.Join(dirPath, info.Name()))
+	}
+
+	return filePaths, nil
+}
+
+// walkFiles returns the paths of the non-directory files in
+// dirPath and its subdirectories in lexical order.
+//
+// Symbolic links to directories are skipped unless followSymlinks
+// is true. Each directory is only walked once when following
+// symbolic links, which prevents cycles.
+func walkFiles(dirPath string, followSymlinks bool) ([]string, error) {
+	visited := make(map[string]struct{})
+
+	var filePaths []string
+
+	var walk func(string) error
+
+	walk = func(root string) error {
+		realRoot, err := filepath.EvalSymlinks(root)
+		if err != nil {
+			return err
+		}
+
+		if _, alreadyVisited := visited[realRoot]; alreadyVisited {
+			return nil
+		}
+
+		visited[realRoot] = struct{}{}
+
+		return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			switch {
+			case err != nil && path == root:
+				return err
+			case err != nil:
+				warnf("failed to read %q - %s", path, err)
+
+				return nil
+			case d.IsDir() && path != root && strings.HasPrefix(d.Name(), "."):
+				return fs.SkipDir
+			case d.IsDir():
+				return nil
+			case d.Type()&fs.ModeSymlink == 0:
+				filePaths = append(filePaths, path)
+
+				return nil
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				warnf("failed to stat symbolic link %q - %s", path, err)
+
+				return nil
+			}
+
+			if !info.IsDir() {
+				filePaths = append(filePaths, path)
+
+				return nil
+			}
+
+			if !followSymlinks {
+				return nil
+			}
+
+			err = walk(path)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				warnf("failed to walk %q - %s", path, err)
+			}
+
+			return nil
+		})
+	}
+
+	err := walk(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return filePaths, nil
+}
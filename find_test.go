@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFindExesInDirResolvesSymlinkOnce(t *testing.T) {
+	ctl := newTestExecCtl(t, realClock{})
+
+	release := filepath.Join(t.TempDir(), "release-1")
+
+	err := os.Mkdir(release, 0o700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"a.sh":              "#!/bin/sh\n",
+		"b.sh":              "#!/bin/sh\n",
+		dirConfigFileName:   "timeout = \"5m\"\n",
+		wakedIgnoreFileName: "b.sh\n",
+	}
+
+	for name, contents := range files {
+		err := os.WriteFile(filepath.Join(release, name), []byte(contents), 0o700)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	current := filepath.Join(ctl.exesDirs[0], "current")
+
+	err = os.Symlink(release, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exes, err := ctl.findExesInDir(newEvent(wakeNotification), current)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(exes) != 1 {
+		t.Fatalf("got %d executable(s) - want 1", len(exes))
+	}
+
+	if want := filepath.Join(release, "a.sh"); exes[0].path != want {
+		t.Fatalf("got path %q - want %q", exes[0].path, want)
+	}
+
+	if exes[0].timeout != 5*time.Minute {
+		t.Fatalf("got timeout %s - want 5m", exes[0].timeout)
+	}
+}
+
+func TestExeForEventMinAgeOnlyLoggedForEvent(t *testing.T) {
+	ctl := newTestExecCtl(t, realClock{})
+	ctl.minAge = time.Hour
+
+	wake := writeTestExe(t, ctl, "a.sh", "true")
+	sleep := writeTestExe(t, ctl, "b"+onSleepStr+".sh", "true")
+
+	tests := []struct {
+		name    string
+		ev      string
+		exePath string
+		wantLog bool
+	}{
+		{name: "wake program on wake", ev: wakeNotification, exePath: wake.path, wantLog: true},
+		{name: "sleep program on wake", ev: wakeNotification, exePath: sleep.path},
+		{name: "wake program on sleep", ev: sleepNotification, exePath: wake.path},
+		{name: "wake program on unlock", ev: unlockNotification, exePath: wake.path},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logs := captureLog(t)
+
+			_, ok := ctl.exeForEvent(newEvent(test.ev), test.exePath, nil)
+			if ok {
+				t.Fatal("recently modified program would be executed")
+			}
+
+			logged := strings.Contains(logs.String(), "-"+minAgeArg)
+			if logged != test.wantLog {
+				t.Fatalf("got logged: %t - want %t - log:\n%s", logged, test.wantLog, logs)
+			}
+		})
+	}
+}
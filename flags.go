@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// stringList is a flag.Value that can be specified
+// multiple times.
+type stringList []string
+
+func (o *stringList) String() string {
+	if o == nil {
+		return ""
+	}
+
+	return strings.Join(*o, ", ")
+}
+
+func (o *stringList) Set(s string) error {
+	*o = append(*o, s)
+
+	return nil
+}
+
+// expandPath expands environment variables (e.g., "$HOME") and
+// a leading "~" in filePath. Expansion is needed because launchd
+// does not pass arguments through a shell.
+func expandPath(filePath string) (string, error) {
+	expanded := os.ExpandEnv(filePath)
+
+	if expanded == "~" || strings.HasPrefix(expanded, "~/") {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to expand '~' in %q - %w", filePath, err)
+		}
+
+		expanded = filepath.Join(homeDir, strings.TrimPrefix(expanded, "~"))
+	}
+
+	if expanded == "" {
+		return "", fmt.Errorf("%q expanded to an empty path", filePath)
+	}
+
+	return expanded, nil
+}
+
+// parseKeyValue parses a string in the format "key=value".
+func parseKeyValue(s string) (string, string, error) {
+	key, value, found := strings.Cut(s, "=")
+	if !found || key == "" {
+		return "", "", fmt.Errorf("%q is not in the format 'key=value'", s)
+	}
+
+	return key, value, nil
+}
+
+// installArgs returns the arguments that the launchd job created
+// by -install passes to the program. They are the flags that were
+// specified on the command line (the config file is read by the
+// job itself) followed by the positional arguments. Paths are made
+// absolute because launchd executes the program in '/'.
+func installArgs(flags *flag.FlagSet) ([]string, error) {
+	var args []string
+	var err error
+
+	flags.Visit(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+
+		switch f.Name {
+		case installArg, systemArg, installLogArg:
+			return
+		case onceArg, simulateArg, dryRunArg, statusArg, uninstallArg:
+			err = fmt.Errorf("-%s cannot be combined with -%s", f.Name, installArg)
+
+			return
+		}
+
+		values := []string{f.Value.String()}
+		if list, isList := f.Value.(*stringList); isList {
+			values = *list
+		}
+
+		for _, value := range values {
+			if isPathFlag(f.Name, value) {
+				value, err = absPath(value)
+				if err != nil {
+					err = fmt.Errorf("invalid -%s path - %w", f.Name, err)
+
+					return
+				}
+			}
+
+			args = append(args, "-"+f.Name+"="+value)
+		}
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, arg := range flags.Args() {
+		arg, err = absPath(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid executables directory path - %w", err)
+		}
+
+		args = append(args, arg)
+	}
+
+	return args, nil
+}
+
+// isPathFlag returns true if value, the value of the flag named
+// name, is a path that is relative to the working directory.
+// An empty value disables some flags (e.g., -state-dir), and a
+// -run-hook without a separator is looked up in PATH.
+func isPathFlag(name string, value string) bool {
+	switch {
+	case value == "":
+		return false
+	case name == runHookArg:
+		return strings.ContainsRune(value, filepath.Separator)
+	}
+
+	switch name {
+	case dirArg, manifestArg, configArg, workdirArg, logDirArg, logFileArg,
+		lockFileArg, statusSockArg, stateDirArg:
+		return true
+	default:
+		return false
+	}
+}
+
+// absPath expands filePath using expandPath and returns its
+// absolute path.
+func absPath(filePath string) (string, error) {
+	expanded, err := expandPath(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Abs(expanded)
+}
@@ -2,4 +2,10 @@ module gitlab.com/stephen-fox/waked
 
 go 1.22.2
 
-require github.com/progrium/darwinkit v0.5.0
+require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/godbus/dbus/v5 v5.2.2
+	github.com/progrium/darwinkit v0.5.0
+)
+
+require golang.org/x/sys v0.27.0 // indirect
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// wakedIgnoreFileName is the name of the file in the executables
+// directory containing gitignore-style patterns of files to ignore.
+// Since its name begins with '.', the file itself is never executed.
+const wakedIgnoreFileName = "." + appName + "ignore"
+
+// ignoreRule is a single pattern from an ignore file.
+type ignoreRule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// ignoreRules is a list of gitignore-style patterns, which support
+// the following subset of the gitignore syntax:
+//
+//   - Blank lines and lines beginning with '#' are ignored
+//   - A leading '!' re-includes files excluded by a previous pattern
+//   - A trailing '/' only matches directories
+//   - A pattern containing a '/' is matched against the path
+//     relative to the executables directory. Otherwise, it is
+//     matched against the file or directory name at any level
+//   - A leading '**/' matches in all directories
+//
+// Patterns use the syntax of path.Match.
+type ignoreRules []ignoreRule
+
+// loadIgnoreFile parses the ignore file in dirPath. A missing ignore
+// file results in empty rules. Invalid patterns are logged and skipped.
+func loadIgnoreFile(dirPath string) ignoreRules {
+	filePath := filepath.Join(dirPath, wakedIgnoreFileName)
+
+	raw, err := os.ReadFile(filePath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			warnf("failed to read ignore file %q - %s", filePath, err)
+		}
+
+		return nil
+	}
+
+	var rules ignoreRules
+
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var rule ignoreRule
+
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+
+		line = strings.TrimPrefix(line, "**/")
+
+		if strings.HasPrefix(line, "/") {
+			line = line[1:]
+			rule.anchored = true
+		} else if strings.Contains(line, "/") {
+			rule.anchored = true
+		}
+
+		rule.pattern = line
+
+		_, err := path.Match(rule.pattern, "")
+		if rule.pattern == "" || err != nil {
+			warnf("%s:%d: ignoring invalid pattern %q",
+				filePath, lineNum, scanner.Text())
+
+			continue
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+// isIgnored returns true if the file at relPath, which is relative
+// to the executables directory, is ignored. A file is also ignored
+// if any of its parent directories are ignored.
+func (o ignoreRules) isIgnored(relPath string) bool {
+	if len(o) == 0 {
+		return false
+	}
+
+	relPath = filepath.ToSlash(relPath)
+	parts := strings.Split(relPath, "/")
+
+	for i := range parts {
+		isDir := i < len(parts)-1
+
+		if o.matches(strings.Join(parts[:i+1], "/"), isDir) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (o ignoreRules) matches(relPath string, isDir bool) bool {
+	ignored := false
+
+	for _, rule := range o {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		target := path.Base(relPath)
+		if rule.anchored {
+			target = relPath
+		}
+
+		matched, _ := path.Match(rule.pattern, target)
+		if matched {
+			ignored = !rule.negate
+		}
+	}
+
+	return ignored
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIgnoreRulesIsIgnored(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		ignored []string
+		kept    []string
+	}{
+		{
+			name:    "unanchored pattern matches at any level",
+			file:    "*.bak\n",
+			ignored: []string{"a.bak", "sub/b.bak", "sub/deeper/c.bak"},
+			kept:    []string{"a.sh", "bak", "sub/a.sh"},
+		},
+		{
+			name:    "pattern containing a slash is anchored",
+			file:    "sub/*.sh\n",
+			ignored: []string{"sub/a.sh"},
+			kept:    []string{"a.sh", "other/sub/a.sh", "sub/deeper/a.sh"},
+		},
+		{
+			name:    "leading slash anchors pattern",
+			file:    "/a.sh\n",
+			ignored: []string{"a.sh"},
+			kept:    []string{"sub/a.sh"},
+		},
+		{
+			name:    "leading double star matches in all directories",
+			file:    "**/tmp\n",
+			ignored: []string{"tmp", "sub/tmp", "tmp/a.sh"},
+			kept:    []string{"tmp.sh"},
+		},
+		{
+			name:    "negation re-includes files",
+			file:    "*.sh\n!keep.sh\n",
+			ignored: []string{"a.sh", "sub/b.sh"},
+			kept:    []string{"keep.sh", "sub/keep.sh", "a.py"},
+		},
+		{
+			name:    "later pattern overrides negation",
+			file:    "*.sh\n!keep.sh\nkeep.sh\n",
+			ignored: []string{"a.sh", "keep.sh"},
+		},
+		{
+			name:    "negation does not re-include files in ignored directory",
+			file:    "disabled/\n!disabled/keep.sh\n",
+			ignored: []string{"disabled/a.sh", "disabled/keep.sh"},
+		},
+		{
+			name:    "trailing slash only matches directories",
+			file:    "old/\n",
+			ignored: []string{"old/a.sh", "sub/old/a.sh"},
+			kept:    []string{"old", "sub/old"},
+		},
+		{
+			name:    "comments and blank lines are ignored",
+			file:    "# *.sh\n\n   \n*.bak\n",
+			ignored: []string{"a.bak"},
+			kept:    []string{"a.sh", "# *.sh"},
+		},
+		{
+			name:    "escaped special characters are literal",
+			file:    "\\#notes\n\\!important\nliteral\\*\n",
+			ignored: []string{"#notes", "!important", "literal*"},
+			kept:    []string{"notes", "important", "literally"},
+		},
+		{
+			name:    "invalid patterns are skipped",
+			file:    "[\n*.bak\n",
+			ignored: []string{"a.bak"},
+			kept:    []string{"[", "a.sh"},
+		},
+		{
+			name: "no ignore file",
+			kept: []string{"a.sh", "sub/a.sh"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			if test.file != "" {
+				err := os.WriteFile(filepath.Join(dir, wakedIgnoreFileName), []byte(test.file), 0o600)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			captureLog(t)
+
+			rules := loadIgnoreFile(dir)
+
+			for _, relPath := range test.ignored {
+				if !rules.isIgnored(relPath) {
+					t.Errorf("%q is not ignored", relPath)
+				}
+			}
+
+			for _, relPath := range test.kept {
+				if rules.isIgnored(relPath) {
+					t.Errorf("%q is ignored", relPath)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// launchdJob describes the launchd job that runs the program
+// as a daemon.
+type launchdJob struct {
+	// system is true if the job is a system-wide launch daemon
+	// rather than a per-user launch agent.
+	system bool
+
+	// exePath is the path to the program's executable.
+	exePath string
+
+	// args are the arguments passed to the program.
+	args []string
+
+	// logPath, if non-empty, is the file that the program's
+	// output is written to.
+	logPath string
+}
+
+// install installs a launchd job that runs the program with
+// args, which are returned by installArgs.
+func (o *execCtl) install(system bool, logPath string, args []string) error {
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path - %w", err)
+	}
+
+	job := launchdJob{
+		system:  system,
+		exePath: exePath,
+		args:    args,
+	}
+
+	if logPath != "" {
+		job.logPath, err = filepath.Abs(logPath)
+		if err != nil {
+			return fmt.Errorf("failed to get absolute path of log file - %w", err)
+		}
+	}
+
+	return installLaunchdJob(job)
+}
+
+// launchdPlistPath returns the path to the job's plist file.
+func launchdPlistPath(system bool) (string, error) {
+	if system {
+		return filepath.Join("/Library/LaunchDaemons", launchdLabel+".plist"), nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory - %w", err)
+	}
+
+	return filepath.Join(homeDir, "Library", "LaunchAgents", launchdLabel+".plist"), nil
+}
+
+// installLaunchdJob writes the job's plist file and loads it
+// using launchctl. An existing plist file is replaced.
+func installLaunchdJob(job launchdJob) error {
+	if job.system && os.Geteuid() != 0 {
+		return errors.New("installing a system-wide launch daemon requires root")
+	}
+
+	plistPath, err := launchdPlistPath(job.system)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stat(plistPath)
+	if err == nil {
+		// The job must be unloaded before it can be
+		// loaded again.
+		_ = launchctl("unload", plistPath)
+	}
+
+	err = os.MkdirAll(filepath.Dir(plistPath), 0o755)
+	if err != nil {
+		return fmt.Errorf("failed to create plist directory - %w", err)
+	}
+
+	err = os.WriteFile(plistPath, job.plist(), 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to write plist file - %w", err)
+	}
+
+	err = launchctl("load", "-w", plistPath)
+	if err != nil {
+		return err
+	}
+
+	infof("installed %s", plistPath)
+
+	return nil
+}
+
+// uninstallLaunchdJob unloads the job using launchctl and
+// removes its plist file.
+func uninstallLaunchdJob(system bool) error {
+	plistPath, err := launchdPlistPath(system)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stat(plistPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat plist file - %w", err)
+	}
+
+	err = launchctl("unload", "-w", plistPath)
+	if err != nil {
+		warnf("%s", err)
+	}
+
+	err = os.Remove(plistPath)
+	if err != nil {
+		return fmt.Errorf("failed to remove plist file - %w", err)
+	}
+
+	infof("uninstalled %s", plistPath)
+
+	return nil
+}
+
+func launchctl(args ...string) error {
+	launchctl := exec.Command("/bin/launchctl", args...)
+
+	output, err := launchctl.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("launchctl failed (%v) - %w - output: %q",
+			launchctl.Args, err, output)
+	}
+
+	return nil
+}
+
+// plist returns the job's launchd plist.
+func (o launchdJob) plist() []byte {
+	buf := bytes.NewBuffer(nil)
+
+	str := func(s string) string {
+		escaped := bytes.NewBuffer(nil)
+		_ = xml.EscapeText(escaped, []byte(s))
+
+		return "<string>" + escaped.String() + "</string>"
+	}
+
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+  <key>Label</key>
+  ` + str(launchdLabel) + `
+  <key>ProgramArguments</key>
+  <array>
+`)
+
+	for _, arg := range append([]string{o.exePath}, o.args...) {
+		buf.WriteString("    " + str(arg) + "\n")
+	}
+
+	buf.WriteString(`  </array>
+  <key>RunAtLoad</key>
+  <true/>
+  <key>KeepAlive</key>
+  <true/>
+`)
+
+	if o.logPath != "" {
+		buf.WriteString("  <key>StandardOutPath</key>\n  " + str(o.logPath) + "\n")
+		buf.WriteString("  <key>StandardErrorPath</key>\n  " + str(o.logPath) + "\n")
+	}
+
+	buf.WriteString("</dict>\n</plist>\n")
+
+	return buf.Bytes()
+}
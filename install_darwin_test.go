@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestLaunchdJobPlistRoundTrip(t *testing.T) {
+	job := launchdJob{
+		exePath: "/usr/local/bin/waked",
+		args:    []string{"-dir=/Users/me/waked & co", "-timeout=5m0s"},
+		logPath: "/Users/me/Library/Logs/<waked>.log",
+	}
+
+	v, err := decodePlist(bytes.NewReader(job.plist()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{
+		"Label": launchdLabel,
+		"ProgramArguments": []any{
+			"/usr/local/bin/waked",
+			"-dir=/Users/me/waked & co",
+			"-timeout=5m0s",
+		},
+		"RunAtLoad":         true,
+		"KeepAlive":         true,
+		"StandardOutPath":   "/Users/me/Library/Logs/<waked>.log",
+		"StandardErrorPath": "/Users/me/Library/Logs/<waked>.log",
+	}
+
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("got %#v - want %#v", v, want)
+	}
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"errors"
+)
+
+var launchdUnsupportedErr = errors.New("launchd jobs are only supported on macOS - " +
+	"please use a systemd unit instead")
+
+func (o *execCtl) install(system bool, logPath string, args []string) error {
+	return launchdUnsupportedErr
+}
+
+func uninstallLaunchdJob(system bool) error {
+	return launchdUnsupportedErr
+}
@@ -0,0 +1,11 @@
+package main
+
+// defaultInterpreters maps file extensions to the interpreters
+// that execute them unless -interpreter specifies otherwise.
+//
+// AppleScript files cannot be executed directly (compiled
+// scripts are not text files, so they cannot have a shebang).
+var defaultInterpreters = map[string][]string{
+	".applescript": {"/usr/bin/osascript"},
+	".scpt":        {"/usr/bin/osascript"},
+}
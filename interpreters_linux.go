@@ -0,0 +1,5 @@
+package main
+
+// defaultInterpreters maps file extensions to the interpreters
+// that execute them unless -interpreter specifies otherwise.
+var defaultInterpreters = map[string][]string{}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+)
+
+// checkIfLocked returns true if the screen is locked. The
+// CoreGraphics session dictionary is used if it is available.
+// Otherwise, the lock state is read from ioreg.
+func checkIfLocked(ctx context.Context) (bool, error) {
+	isLocked, ok := sessionScreenIsLocked()
+	if ok {
+		return isLocked, nil
+	}
+
+	return checkIfLockedIoreg(ctx)
+}
+
+// Based on work by Joel Bruner:
+// https://stackoverflow.com/a/66723000
+func checkIfLockedIoreg(ctx context.Context) (bool, error) {
+	// /usr/sbin/ioreg -n Root -d1 -a
+	ioreg := exec.CommandContext(
+		ctx,
+		"/usr/sbin/ioreg",
+		"-n",
+		"Root",
+		"-d1",
+		"-a")
+
+	var stderr bytes.Buffer
+	ioreg.Stderr = &stderr
+
+	ioregOutput, err := ioreg.Output()
+	if err != nil {
+		return false, fmt.Errorf("ioreg failed (%v) - %w - output: %q",
+			ioreg.Args, err, stderr.Bytes())
+	}
+
+	props, err := decodePlist(bytes.NewReader(ioregOutput))
+	if err != nil {
+		return false, fmt.Errorf("failed to parse ioreg output - %w", err)
+	}
+
+	const coreGraphicsParam = "CGSSessionScreenIsLocked"
+
+	// The parameter is only present while the screen is locked.
+	isLocked, ok := plistLookup(props, "IOConsoleUsers", "0", coreGraphicsParam)
+	if !ok {
+		return false, nil
+	}
+
+	b, _ := isLocked.(bool)
+
+	return b, nil
+}
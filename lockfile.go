@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// lockFile is an exclusive lock on a file that prevents multiple
+// instances of the program from running at the same time.
+type lockFile struct {
+	f *os.File
+}
+
+// acquireLockFile acquires an exclusive lock on the file at
+// filePath, creating it if needed. It fails if another process
+// holds the lock.
+func acquireLockFile(filePath string) (*lockFile, error) {
+	f, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file - %w", err)
+	}
+
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err != nil {
+		f.Close()
+
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			pid, _ := os.ReadFile(filePath)
+
+			return nil, fmt.Errorf("another instance of %s is already running (pid: %q, lock file: %q)",
+				appName, pid, filePath)
+		}
+
+		return nil, fmt.Errorf("failed to lock %q - %w", filePath, err)
+	}
+
+	// The PID is informational - the lock is what matters.
+	err = f.Truncate(0)
+	if err == nil {
+		_, err = f.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+	}
+	if err != nil {
+		f.Close()
+
+		return nil, fmt.Errorf("failed to write pid to lock file - %w", err)
+	}
+
+	return &lockFile{f: f}, nil
+}
+
+// Close releases the lock.
+func (o *lockFile) Close() error {
+	return o.f.Close()
+}
@@ -0,0 +1,44 @@
+package main
+
+/*
+#cgo LDFLAGS: -framework CoreGraphics -framework CoreFoundation
+
+#include <CoreFoundation/CoreFoundation.h>
+#include <CoreGraphics/CoreGraphics.h>
+
+// sessionScreenIsLocked returns 1 if the current session's screen
+// is locked, 0 if it is not, and -1 if there is no session
+// dictionary (e.g., when not running in a login session).
+static int sessionScreenIsLocked() {
+	CFDictionaryRef session = CGSessionCopyCurrentDictionary();
+	if (session == NULL) {
+		return -1;
+	}
+
+	int isLocked = 0;
+
+	CFTypeRef value = CFDictionaryGetValue(session, CFSTR("CGSSessionScreenIsLocked"));
+	if (value != NULL && CFGetTypeID(value) == CFBooleanGetTypeID()) {
+		isLocked = CFBooleanGetValue((CFBooleanRef)value);
+	}
+
+	CFRelease(session);
+
+	return isLocked;
+}
+*/
+import "C"
+
+// sessionScreenIsLocked returns true if the screen is locked
+// according to the CoreGraphics session dictionary. The second
+// return value is false if the session dictionary is unavailable.
+func sessionScreenIsLocked() (bool, bool) {
+	switch C.sessionScreenIsLocked() {
+	case -1:
+		return false, false
+	case 0:
+		return false, true
+	default:
+		return true, true
+	}
+}
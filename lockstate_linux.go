@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	logindSessionIface = "org.freedesktop.login1.Session"
+	logindUserIface    = "org.freedesktop.login1.User"
+)
+
+// checkIfLocked returns true if the login session returned by
+// logindSession is locked according to systemd-logind's
+// LockedHint property.
+func checkIfLocked(ctx context.Context) (bool, error) {
+	conn, err := dbus.ConnectSystemBus(dbus.WithContext(ctx))
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to system bus - %w", err)
+	}
+	defer conn.Close()
+
+	sessionPath, err := logindSession(conn)
+	if err != nil {
+		return false, err
+	}
+
+	return sessionLockedHint(conn, sessionPath)
+}
+
+// logindSession returns the object path of the login session
+// whose lock state is used. This is the session containing the
+// program if there is one. Otherwise (e.g., when the program is
+// executed by a systemd user unit, which is not part of a session),
+// it is the current user's display session.
+func logindSession(conn *dbus.Conn) (dbus.ObjectPath, error) {
+	manager := conn.Object(logindDest, logindPath)
+
+	var sessionPath dbus.ObjectPath
+
+	err := manager.Call(logindManagerIface+".GetSessionByPID", 0, uint32(os.Getpid())).
+		Store(&sessionPath)
+	if err == nil {
+		return sessionPath, nil
+	}
+
+	var userPath dbus.ObjectPath
+
+	err = manager.Call(logindManagerIface+".GetUser", 0, uint32(os.Getuid())).
+		Store(&userPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to get login session - not in a session and failed to get user - %w", err)
+	}
+
+	display, err := conn.Object(logindDest, userPath).GetProperty(logindUserIface + ".Display")
+	if err != nil {
+		return "", fmt.Errorf("failed to get user's Display property - %w", err)
+	}
+
+	var session struct {
+		ID   string
+		Path dbus.ObjectPath
+	}
+
+	err = display.Store(&session)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode user's Display property - %w", err)
+	}
+
+	// logind uses "/" when the user does not have
+	// a display session.
+	if session.ID == "" || session.Path == "/" {
+		return "", fmt.Errorf("user %d does not have a display session", os.Getuid())
+	}
+
+	return session.Path, nil
+}
+
+// sessionLockedHint returns the LockedHint property of the
+// session at sessionPath.
+func sessionLockedHint(conn *dbus.Conn, sessionPath dbus.ObjectPath) (bool, error) {
+	lockedHint, err := conn.Object(logindDest, sessionPath).
+		GetProperty(logindSessionIface + ".LockedHint")
+	if err != nil {
+		return false, fmt.Errorf("failed to get session's LockedHint property - %w", err)
+	}
+
+	isLocked, ok := lockedHint.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("session's LockedHint property is a %T, not a bool",
+			lockedHint.Value())
+	}
+
+	return isLocked, nil
+}
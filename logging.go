@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+// logLevel is the severity of a log message.
+type logLevel int
+
+const (
+	debugLevel logLevel = iota
+	infoLevel
+	warnLevel
+	errorLevel
+)
+
+const (
+	stderrLogTarget = "stderr"
+	osLogTarget     = "os_log"
+	fileLogTarget   = "file"
+)
+
+// minLogLevel is the least severe level that is logged.
+var minLogLevel = infoLevel
+
+// logSink, if non-nil, receives the messages logged by logf
+// rather than the standard logger.
+var logSink func(level logLevel, msg string)
+
+// setupLogging configures where log messages are written to.
+// filePath is only used by fileLogTarget.
+func setupLogging(target string, filePath string) error {
+	switch target {
+	case stderrLogTarget:
+		if filePath != "" {
+			return fmt.Errorf("a log file can only be specified if the log target is %q",
+				fileLogTarget)
+		}
+	case osLogTarget:
+		osLog, err := newOSLog(launchdLabel)
+		if err != nil {
+			return err
+		}
+
+		// The unified logging system records the time.
+		log.SetFlags(0)
+		log.SetOutput(osLog)
+
+		logSink = osLog.log
+	case fileLogTarget:
+		if filePath == "" {
+			return fmt.Errorf("a log file must be specified if the log target is %q",
+				fileLogTarget)
+		}
+
+		f, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to open log file - %w", err)
+		}
+
+		log.SetOutput(f)
+	default:
+		return fmt.Errorf("unknown log target: %q (supported targets are: %s, %s, %s)",
+			target, stderrLogTarget, osLogTarget, fileLogTarget)
+	}
+
+	return nil
+}
+
+// prefix returns the string that log messages of the level
+// start with. Informational messages have no prefix.
+func (o logLevel) prefix() string {
+	switch o {
+	case debugLevel:
+		return "[debug] "
+	case warnLevel:
+		return "[warn] "
+	case errorLevel:
+		return "[error] "
+	default:
+		return ""
+	}
+}
+
+func logf(level logLevel, format string, args ...any) {
+	if level < minLogLevel {
+		return
+	}
+
+	msg := level.prefix() + fmt.Sprintf(format, args...)
+
+	if logSink != nil {
+		logSink(level, msg)
+
+		return
+	}
+
+	log.Print(msg)
+}
+
+func debugf(format string, args ...any) {
+	logf(debugLevel, format, args...)
+}
+
+func infof(format string, args ...any) {
+	logf(infoLevel, format, args...)
+}
+
+func warnf(format string, args ...any) {
+	logf(warnLevel, format, args...)
+}
+
+func errorf(format string, args ...any) {
+	logf(errorLevel, format, args...)
+}
@@ -10,19 +10,21 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net"
+	"net/url"
 	"os"
 	"os/exec"
-	"os/signal"
+	"os/user"
 	"path/filepath"
 	"runtime"
+	"runtime/debug"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
-
-	"github.com/progrium/darwinkit/macos"
-	"github.com/progrium/darwinkit/macos/appkit"
-	"github.com/progrium/darwinkit/macos/foundation"
 )
 
 const (
@@ -31,26 +33,418 @@ const (
 	usage = appName + `
 
 SYNOPSIS
-  ` + appName + ` [options] [directory-path]
+  ` + appName + ` [options] [directory-path...]
 
 DESCRIPTION
   ` + appName + ` executes programs when macOS resumes from sleep. By default,
   it executes all programs found in directory-path. If directory-path
-  is not specified, then '` + defaultExesDirPath + `' is used.
+  is not specified, then '` + defaultExesDirPath + `' is used. Files that
+  do not have an executable permission bit set are ignored.
+
+  Multiple directories can be specified as additional arguments or using
+  '-` + dirArg + `'. Programs with the same name in different directories are
+  all executed. Environment variables (e.g., '$HOME') and a leading '~'
+  are expanded in directory paths, which is useful when ` + appName + ` is not
+  started by a shell (e.g., by launchd).
+
+  directory-path can also be a glob pattern (e.g., '/opt/scripts/wake-*.sh'),
+  in which case the files that match it are executed. The pattern is
+  re-evaluated for each event, so files that are added later are picked
+  up. Quote the pattern to prevent the shell from expanding it.
+
+  If directory-path is briefly unavailable after waking (e.g., because it
+  is on a network share that has not been remounted yet), '-` + dirRetryArg + `'
+  keeps trying to read it rather than skipping the wake event's programs.
+
+  If directory-path is a symbolic link, it is resolved once per event, and
+  programs are executed from the directory it points to. This allows
+  deploying new programs atomically by pointing the link at a different
+  directory (e.g., 'ln -sfn releases/2 current'). Alternatively, '-` + minAgeArg + `'
+  prevents executing programs that were modified recently, and may still
+  be being copied. Such programs are not executed until the next event.
+
+  Hidden files (files whose names begin with '.') and text editor
+  temporary files (e.g., 'foo.sh~' and '.foo.sh.swp') are also ignored.
+  Additional files can be ignored using '-` + ignoreArg + `' or by listing
+  gitignore-style patterns in a file named '` + wakedIgnoreFileName + `' in
+  directory-path. The file is re-read for each event.
+
+  When '-` + strictPermsArg + `' is specified, programs that are group- or
+  world-writable, or whose directory is, are skipped with a warning. This
+  prevents other users from tampering with programs executed as root.
+
+  Programs can be temporarily disabled without moving them using
+  '-` + skipArg + `' (e.g., '-` + skipArg + ` backup.sh,sync-*'). Alternatively, '-` + onlyArg + `'
+  executes only the programs whose names match. Both accept comma-separated
+  names or glob patterns that are matched against the program's file name.
+
+  Files can be executed by an interpreter based on their extension using
+  '-` + interpreterArg + `' (e.g., '-` + interpreterArg + ` .py=/usr/bin/python3'). Such files
+  do not need to be executable. On macOS, AppleScript files ('.scpt' and
+  '.applescript') are executed by osascript unless '-` + interpreterArg + `'
+  specifies otherwise.
+
+  Subdirectories of directory-path are ignored unless '-` + recursiveArg + `' is
+  specified. Symbolic links to directories are not followed unless
+  '-` + followLinksArg + `' is also specified.
+
+  When it starts, ` + appName + ` warns about programs with obvious problems,
+  such as files that are not executable or are empty, shebangs whose
+  interpreter does not exist, and names that prevent them from ever
+  being executed (e.g., 'foo` + onSleepStr + onLockStr + `.sh').
 
   Executables containing '` + needsUnlockStr + `' in their name will only be executed
-  once the screen is unlocked.
+  once the screen is unlocked. They are executed on wake if the screen is
+  already unlocked. Otherwise, they are executed when the screen is next
+  unlocked. Unlocking the screen also executes them if they are not
+  already running and were not started within the last 30 seconds.
+  '-` + unlockOnlyArg + `' treats every wake program this way, which is equivalent
+  to adding '` + needsUnlockStr + `' to all of their names.
+
+  Executables containing '` + needsACStr + `' in their name will only be executed
+  while the computer is connected to AC power. While it is running on
+  battery, the programs are re-checked every retry interval. Waiting for
+  AC power does not count towards '-` + maxRetriesArg + `'.
+
+  Executables containing '` + needsNetworkStr + `' in their name will only be
+  executed once the network is available, which is re-checked every 2
+  seconds. By default, the network is available once there is a default
+  route. Use '-` + networkHostArg + `' to wait for a specific host instead.
+  Similarly, '-` + waitForArg + `' delays all wake programs until the specified
+  address accepts TCP connections.
+
+  Executables containing '` + timeoutInNameStr + `<duration>' in their name are killed
+  if they run for longer than the duration (e.g., 'backup` + timeoutInNameStr + `1h30m.sh').
+  The duration is a sequence of numbers followed by a unit: 'ns', 'us',
+  'ms', 's', 'm', or 'h'. Programs are otherwise killed if they run for
+  longer than the duration specified by '-` + timeoutArg + `'. The '-` + runTimeoutArg + `'
+  option limits how long all of the programs executed for an event may
+  take, including retries. '-` + timeoutActArg + ` ` + warnTimeoutAction + `' logs a warning when a wake
+  program times-out rather than killing it, which is useful for genuinely
+  long tasks.
+
+  Executables containing '` + onSleepStr + `' in their name are executed when
+  macOS is about to sleep rather than when it wakes. macOS only waits
+  briefly before sleeping, so these programs are killed if they do not
+  exit within the duration specified by '-` + sleepTimeoutArg + `'. They are
+  not retried.
+
+  Executables containing '` + onLockStr + `' in their name are executed when
+  the screen is locked rather than when macOS wakes. Like wake programs,
+  they are retried if they fail.
+
+  Executables containing '` + onStartStr + `' in their name are executed once
+  when ` + appName + ` starts (e.g., after logging in) rather than when macOS
+  wakes. Like wake programs, they are retried if they fail, and are stopped
+  by the next wake event.
+
+  Additional notifications can be observed using '-` + notificationArg + `'. Each
+  notification is mapped to a string. Executables containing the string
+  in their name are executed when the notification is posted rather than
+  when macOS wakes (e.g., '-` + notificationArg + ` com.apple.screensaver.didstart=-on-saver'
+  executes 'pause-music-on-saver.sh' when the screen saver starts).
+
+  On Linux, sleep and wake events are received from systemd-logind.
+  ` + appName + ` holds a "delay" inhibitor lock so that logind waits (up to its
+  InhibitDelayMaxSec setting) for the sleep programs to exit. The screen
+  lock state is read from the login session's LockedHint property, and
+  lock and unlock events are produced when it changes. The session
+  containing ` + appName + ` is used, or the user's display session if ` + appName + ` is
+  not in a session (e.g., when it is executed by a systemd user unit).
+  The '-` + notificationArg + `', '-` + installArg + `', and '-` + logTargetArg + ` ` + osLogTarget + `' options are
+  only supported on macOS.
+
+  Programs that are stopped (e.g., because they timed-out or because a
+  new wake event occurred) are sent SIGTERM, or the signal specified by
+  '-` + killSignalArg + `' (e.g., 'SIGUSR1'). Programs that do not exit within the
+  duration specified by '-` + termGraceArg + `' are then sent SIGKILL.
+  When ` + appName + ` receives SIGINT or SIGTERM, it stops its programs this way
+  and waits for them to exit before exiting itself. '-` + maxUptimeArg + `' does the
+  same after ` + appName + ` has run for the specified duration, which lets launchd
+  (when KeepAlive is set, as it is by '-` + installArg + `') start a fresh instance.
+  SIGQUIT does the same after logging the stack of each goroutine, which
+  helps diagnose hangs.
+
+  By default, programs that are still running when a wake event occurs
+  are stopped and then re-executed. If '-` + signalOnWakeArg + `' is specified,
+  the programs are sent the specified signal instead and are left running.
+  If '-` + serializeArg + `' is specified, the wake event is instead queued until
+  the programs finish, which is safer for programs that are not idempotent.
+  Wake events that occur while an event is queued are combined with it.
+  If '-` + noRestartArg + `' is specified, the programs that are still running
+  are left alone, and only the programs that are not running are executed.
+
+  Sending SIGHUP to ` + appName + ` rescans directory-path and executes the wake
+  programs that are not already running. Running programs are left alone.
 
   ` + appName + ` will continuously re-execute a program if it exits with a non-zero
-  exit status.
+  exit status. Use '-` + maxRetriesArg + `' to give up after a number of retries.
+  Exit statuses listed in '-` + noRetryCodesArg + `' are treated as success.
+
+  The time, duration, and status of each program's last execution are
+  persisted in a JSON file in '-` + stateDirArg + `' (e.g., '~/Library/Application Support/` + appName + `')
+  so that they survive restarts. This allows '-` + minIntervalArg + `' and the
+  runOnce sidecar field to take executions from before ` + appName + ` started
+  into account.
+
+  Programs are executed concurrently by default. The '-` + sequentialArg + `' option
+  executes them one at a time in order of their names. Like rc.d scripts,
+  names that begin with a number are ordered numerically and come first
+  (e.g., '2-mount' before '10-backup'). Other names are ordered lexically.
+  The priority sidecar field orders programs regardless of their names.
+  A program that fails is retried in the background while the next
+  program starts unless '-` + stopOnErrorArg + `' is specified, in which case
+  ` + appName + ` waits for the program to succeed and stops executing programs
+  if it gives up.
+
+MANIFEST FILES
+  Programs can also be listed in a manifest file specified using
+  '-` + manifestArg + `'. Each line contains the path to a program optionally
+  followed by arguments separated by whitespace. Blank lines and lines
+  starting with '#' are ignored. Relative paths are relative to the
+  manifest's directory. The manifest is re-read for each event.
+
+  A manifest's programs are executed after the programs in directory-path.
+  With '-` + sequentialArg + `', they are executed in the order they are listed.
+  File name conventions (e.g., '` + onSleepStr + `') and sidecar files apply to
+  them as well. If '-` + manifestArg + `' is specified without a directory-path,
+  the default directory is not used.
+
+CONFIG FILE
+  Options can also be specified in a TOML file using '-` + configArg + `'. Each
+  top-level key is the name of an option, and options that may be
+  specified multiple times are lists. Per-program settings go in the
+  '` + configProgramsKey + `' table, keyed by the program's name, and use the same fields
+  as sidecar files. A program's sidecar file overrides them:
+
+    dir = ["~/.waked"]
+    timeout = "15m"
+    max-retries = 3
+    ignore = ["*.bak"]
+
+    [` + configProgramsKey + `."backup.sh"]
+    timeout = "1h"
+    runOnAC = true
+
+SIDECAR FILES
+  A program's execution can be customized by placing a JSON file next
+  to it whose name is the program's name followed by '` + sidecarExt + `'
+  (e.g., 'foo.sh` + sidecarExt + `' configures 'foo.sh'). Files ending in '` + sidecarExt + `'
+  are never executed. The following fields are supported:
+
+    timeout        The maximum amount of time the program may run for
+                   (e.g., "30s"). Overrides the timeout in the program's
+                   name. Defaults to '-` + timeoutArg + `'
+    retryInterval  The amount of time to wait before re-executing the
+                   program after it fails. Defaults to 10 seconds
+    maxRetries     The number of times to re-execute the program after
+                   it fails (0 means unlimited). Defaults to '-` + maxRetriesArg + `'
+    runOnUnlock    Set to true to treat the program as if its name
+                   contained '` + needsUnlockStr + `'
+    runOnAC        Set to true to treat the program as if its name
+                   contained '` + needsACStr + `'
+    requiresNetwork
+                   Set to true to treat the program as if its name
+                   contained '` + needsNetworkStr + `'
+    waitFor        A list of addresses that must accept TCP connections
+                   before the program is executed (e.g., ["nas.local:445"]).
+                   Added to the addresses specified by '-` + waitForArg + `'
+    runBetween     The local time of day during which the program may be
+                   executed (e.g., "01:00-05:00"). The range wraps around
+                   midnight if its end is before its start. The program
+                   is skipped, not retried, outside of the range
+    minInterval    Skip the program if it succeeded less than the specified
+                   amount of time ago (e.g., "6h"). Defaults to
+                   '-` + minIntervalArg + `'
+    dependsOn      A list of program names (e.g., ["mount.sh"]) that must
+                   succeed before the program is executed. Programs that do
+                   not depend on each other are still executed concurrently.
+                   The program is skipped if a dependency fails. Dependencies
+                   that are skipped (but not deferred until the screen is
+                   unlocked) count as succeeded, and those that are not
+                   executed for the event are ignored
+    priority       An integer that orders the program relative to the others
+                   regardless of its name. Lower priorities are executed
+                   first, and programs with the same priority are ordered
+                   by name. Defaults to 0. When programs are executed
+                   concurrently, it only affects the order they start in
+    group          A name (e.g., "sync") shared by programs that must not
+                   be executed at the same time. Programs in the same
+                   group wait for each other, while different groups are
+                   executed concurrently
+    killSignal     The signal (e.g., "SIGUSR1") sent to the program when it
+                   is stopped. Defaults to '-` + killSignalArg + `'
+    runOnce        Set to true to stop executing the program once it
+                   succeeds. It is retried on later events until then.
+                   The success is recorded in '-` + stateDirArg + `', so it
+                   persists across restarts. The program is executed again
+                   if its contents change
+
+  Sleep programs ignore these fields, except for runBetween, minInterval,
+  runOnce, killSignal, and priority, and are subject to '-` + sleepTimeoutArg + `'.
+
+  A TOML file named '` + dirConfigFileName + `' in the root of directory-path specifies
+  the defaults for the programs in the directory (e.g., 'timeout = "5m"').
+  It supports the same fields as sidecar files, which override it, as do
+  the config file's per-program settings and the timeout in a program's
+  name. It also supports 'sequential', which when set to true executes
+  the directory's programs one at a time in order, like '-` + sequentialArg + `',
+  while other directories' programs are executed concurrently. The file
+  itself is never executed.
+
+ENVIRONMENT
+  Programs are executed in the directory containing them, or in
+  '-` + workdirArg + `' if it is specified. They inherit ` + appName + `'s environment.
+  launchd provides a minimal environment (e.g., PATH does not include
+  Homebrew). Specify '-` + loginShellArg + `' to execute programs using '$SHELL -l'
+  so that the shell's profile is sourced first. zsh (sh on Linux) is used
+  if SHELL is not set. Specify '-` + cleanEnvArg + `' to execute programs with only
+  PATH, HOME, and USER instead, which makes their behavior reproducible.
+  Variables can be added using '-` + envArg + `' (e.g., '-` + envArg + ` BACKUP_HOST=nas.local').
+  The following variables are also set:
+
+    WAKED_EVENT        The name of the notification that triggered
+                       the program (e.g., ` + wakeNotification + `
+                       or ` + unlockNotification + `).
+                       Set to '` + reloadEventName + `' for rescans and '` + startEventName + `' for
+                       programs executed when ` + appName + ` starts
+    WAKED_TIME         The time the notification was received in RFC3339
+                       format
+    WAKED_SINCE_SLEEP  The number of seconds the computer was asleep.
+                       Only set for wake events, and only if ` + appName + `
+                       observed the preceding sleep
+    WAKED_ATTEMPT      The number of times the program has been executed
+                       for the event, including this time (e.g., 2 when it
+                       is retried for the first time)
+
+  If '-` + stdinEventArg + `' is specified, a JSON object describing the event is
+  also written to each program's stdin, which is then closed:
+
+    {"event": "` + wakeNotification + `", "time": "2024-05-01T09:00:00-04:00",
+     "sinceSleep": 3600, "screenLocked": false, "powerSource": "ac"}
+
+  'sinceSleep' is omitted under the same conditions as WAKED_SINCE_SLEEP.
+  'screenLocked' is null and 'powerSource' is omitted if they are unknown.
+  'powerSource' is either "` + acPowerSource + `" or "` + batteryPowerSource + `".
 
 OPTIONS
 `
 
-	helpArg = "h"
+	helpArg         = "h"
+	dirArg          = "dir"
+	versionArg      = "version"
+	sleepTimeoutArg = "sleep-timeout"
+	onceArg         = "once"
+	simulateArg     = "simulate"
+	backoffArg      = "backoff"
+	maxIntervalArg  = "max-interval"
+	maxRetriesArg   = "max-retries"
+	sequentialArg   = "sequential"
+	serializeArg    = "serialize-events"
+	noRestartArg    = "no-restart-on-event"
+	stopOnErrorArg  = "stop-on-error"
+	concurrencyArg  = "concurrency"
+	debounceArg     = "debounce"
+	logDirArg       = "log-dir"
+	workdirArg      = "workdir"
+	cleanEnvArg     = "clean-env"
+	envArg          = "env"
+	recursiveArg    = "recursive"
+	followLinksArg  = "follow-symlinks"
+	ignoreArg       = "ignore"
+	interpreterArg  = "interpreter"
+	argsArg         = "args"
+	termGraceArg    = "term-grace"
+	signalOnWakeArg = "signal-on-wake"
+	statusArg       = "status"
+	statusSockArg   = "status-socket"
+	minSleepArg     = "min-sleep"
+	lockFileArg     = "lock-file"
+	dryRunArg       = "dry-run"
+	installArg      = "install"
+	uninstallArg    = "uninstall"
+	systemArg       = "system"
+	installLogArg   = "install-log"
+	runAsArg        = "run-as"
+	noRetryCodesArg = "no-retry-codes"
+	runHookArg      = "run-hook"
+	networkHostArg  = "network-host"
+	waitForArg      = "wait-for"
+	notifyArg       = "notify"
+	heartbeatArg    = "heartbeat"
+	verboseArg      = "v"
+	logTargetArg    = "log-target"
+	logFileArg      = "log-file"
+	notificationArg = "notification"
+	jitterArg       = "jitter"
+	metricsAddrArg  = "metrics-addr"
+	manifestArg     = "manifest"
+	timeoutArg      = "timeout"
+	runTimeoutArg   = "run-timeout"
+	maxOutputArg    = "max-output"
+	collapseArg     = "collapse-repeats"
+	minIntervalArg  = "min-interval"
+	stdinEventArg   = "stdin-event"
+	configArg       = "config"
+	loginShellArg   = "login-shell"
+	maxUptimeArg    = "max-uptime"
+	onlyArg         = "only"
+	skipArg         = "skip"
+	strictPermsArg  = "strict-perms"
+	stateDirArg     = "state-dir"
+	webhookArg      = "webhook"
+	dirRetryArg     = "dir-retry"
+	killSignalArg   = "kill-signal"
+	unlockOnlyArg   = "unlock-only"
+	minAgeArg       = "min-age"
+	timeoutActArg   = "timeout-action"
 
 	defaultExesDirPath = "/usr/local/etc/" + appName
-	needsUnlockStr     = "-on-unlock"
+
+	// launchdLabel is the label of the launchd job created by
+	// installLaunchdJob. It matches the example plist in the
+	// repository's Library directory. It is also used as the
+	// os_log subsystem.
+	launchdLabel = "com.gitlab.stephen-fox." + appName
+
+	needsUnlockStr  = "-on-unlock"
+	needsACStr      = "-on-ac"
+	needsNetworkStr = "-requires-network"
+	onSleepStr      = "-on-sleep"
+	onLockStr       = "-on-lock"
+	onStartStr      = "-on-start"
+
+	wakeNotification   = "NSWorkspaceDidWakeNotification"
+	sleepNotification  = "NSWorkspaceWillSleepNotification"
+	unlockNotification = "com.apple.screenIsUnlocked"
+	lockNotification   = "com.apple.screenIsLocked"
+
+	defaultExecTimeout = 10 * time.Minute
+
+	killTimeoutAction = "kill"
+	warnTimeoutAction = "warn"
+
+	// unlockDedupeWindow is the amount of time after a program
+	// is started during which an unlock event does not execute
+	// it again. This prevents a wake event that found the screen
+	// unlocked and the unlock event that quickly follows it from
+	// both executing the program.
+	unlockDedupeWindow = 30 * time.Second
+
+	// lockStateTTL is the amount of time the result of
+	// checkIfLocked is reused for.
+	lockStateTTL = time.Second
+
+	// lockCheckTimeout is the maximum amount of time that
+	// checkIfLocked may take. The callers' contexts may not
+	// have a deadline, and a stuck ioreg would otherwise
+	// block them indefinitely.
+	lockCheckTimeout = 5 * time.Second
+
+	// shutdownGrace is the amount of time, in addition to the
+	// -term-grace duration, that waked waits for programs to
+	// exit after it receives a signal.
+	shutdownGrace = 5 * time.Second
 )
 
 func main() {
@@ -63,13 +457,444 @@ func main() {
 func mainWtihError() error {
 	// If we do not runtime.LockOSThread, then we never get events.
 	// This is related to starting a Go routine to monitor for
-	// OS signals.
+	// OS signals. Some event sources (e.g., macOS) must also be
+	// run on the main thread.
 	runtime.LockOSThread()
 
 	help := flag.Bool(helpArg, false, "Display this information")
 
-	// TODO: Use syslog - unfortunately, syslog library is broken
-	// - thanks, Apple: https://github.com/golang/go/issues/59229
+	version := flag.Bool(versionArg, false, "Display version information and exit")
+
+	verbose := flag.Bool(
+		verboseArg,
+		false,
+		"Log debug messages (e.g., each time a program is retried)")
+
+	logTarget := flag.String(
+		logTargetArg,
+		stderrLogTarget,
+		"Where to write log messages. Supported values are:\n"+
+			"'"+stderrLogTarget+"' - Write to stderr\n"+
+			"'"+osLogTarget+"' - Write to the unified logging system, which can\n"+
+			"be viewed using Console.app or 'log stream'\n"+
+			"'"+fileLogTarget+"' - Append to the file specified by -"+logFileArg)
+
+	logFile := flag.String(
+		logFileArg,
+		"",
+		"The file to append log messages to (requires -"+logTargetArg+" "+fileLogTarget+")")
+
+	var dirs stringList
+	flag.Var(
+		&dirs,
+		dirArg,
+		"A directory containing programs to execute. Can be specified\n"+
+			"multiple times. Combined with directory-path arguments")
+
+	manifest := flag.String(
+		manifestArg,
+		"",
+		"A file listing programs to execute, one per line, optionally followed\n"+
+			"by arguments. Combined with the programs in directory-path")
+
+	printStatusAndExit := flag.Bool(
+		statusArg,
+		false,
+		"Display the programs that are being executed by the running instance,\n"+
+			"the last time each event was received, and the last time each program\n"+
+			"was executed and exit")
+
+	statusSocket := flag.String(
+		statusSockArg,
+		defaultRuntimeFilePath(".sock"),
+		"The path to the Unix domain socket used to query the running instance's\n"+
+			"status. Specify an empty string to disable the socket")
+
+	metricsAddr := flag.String(
+		metricsAddrArg,
+		"",
+		"Serve metrics in the Prometheus text format at '/metrics' on the\n"+
+			"specified address (e.g., '127.0.0.1:9100')")
+
+	lockFilePath := flag.String(
+		lockFileArg,
+		defaultRuntimeFilePath(".lock"),
+		"The path to the lock file that prevents multiple instances from\n"+
+			"running at the same time. Specify an empty string to disable locking")
+
+	install := flag.Bool(
+		installArg,
+		false,
+		"Install a launchd job that runs "+appName+" with the specified options\n"+
+			"and exit. The job is started immediately and when the user logs in")
+
+	uninstall := flag.Bool(
+		uninstallArg,
+		false,
+		"Stop and remove the launchd job created by -"+installArg+" and exit")
+
+	system := flag.Bool(
+		systemArg,
+		false,
+		"Install or uninstall a system-wide launch daemon in /Library/LaunchDaemons\n"+
+			"rather than a launch agent in ~/Library/LaunchAgents (requires root)")
+
+	installLog := flag.String(
+		installLogArg,
+		"",
+		"The file that the launchd job created by -"+installArg+" writes its output to")
+
+	dryRun := flag.Bool(
+		dryRunArg,
+		false,
+		"Log the programs that would be executed for each event rather than\n"+
+			"executing them. Can be combined with -"+onceArg+" and -"+simulateArg)
+
+	sleepTimeout := flag.Duration(
+		sleepTimeoutArg,
+		5*time.Second,
+		"The maximum amount of time to wait for programs to exit before sleep")
+
+	once := flag.Bool(
+		onceArg,
+		false,
+		"Execute the wake programs once, wait for them to exit, and then exit.\n"+
+			"Exits with a non-zero status if any program failed")
+
+	simulate := flag.String(
+		simulateArg,
+		"",
+		"Simulate a single event, wait for the programs to exit, and then exit.\n"+
+			"Exits with a non-zero status if any program failed. Supported\n"+
+			"events are: "+strings.Join(simulatedEventNames(), ", "))
+
+	backoffKind := flag.String(
+		backoffArg,
+		fixedBackoff,
+		"The amount of time to wait between retries. Supported values are:\n"+
+			"'"+fixedBackoff+"' - Wait the program's retry interval\n"+
+			"'"+exponentialBackoff+"' - Double the wait after each failure starting\n"+
+			"at the program's retry interval. Resets when the program succeeds\n"+
+			"'"+noBackoff+"' - Retry immediately")
+
+	maxInterval := flag.Duration(
+		maxIntervalArg,
+		5*time.Minute,
+		"The maximum amount of time to wait between retries (0 means no maximum)")
+
+	jitter := flag.Float64(
+		jitterArg,
+		0,
+		"Randomly increase or decrease the amount of time to wait between\n"+
+			"retries by up to the specified fraction (e.g., '0.2' for 20%)")
+
+	maxRetries := flag.Int(
+		maxRetriesArg,
+		0,
+		"The number of times to re-execute a program after it fails before\n"+
+			"giving up (0 means unlimited)")
+
+	noRetryCodes := flag.String(
+		noRetryCodesArg,
+		"",
+		"A comma-separated list of non-zero exit statuses that mean a program\n"+
+			"has nothing to do (e.g., '2,3'). Programs exiting with one of these\n"+
+			"statuses are not retried and are not considered to have failed")
+
+	runHook := flag.String(
+		runHookArg,
+		"",
+		"A program to execute once the programs for an event have exited.\n"+
+			"A JSON summary of the programs' results is written to its stdin")
+
+	networkHost := flag.String(
+		networkHostArg,
+		"",
+		"Consider the network available to programs that require it once a\n"+
+			"TCP connection to the specified address succeeds (e.g., 'nas.local:445').\n"+
+			"By default, the network is available once there is a default route")
+
+	var waitFor stringList
+	flag.Var(
+		&waitFor,
+		waitForArg,
+		"Do not execute wake programs until a TCP connection to the specified\n"+
+			"address succeeds (e.g., 'nas.local:445'). Can be specified multiple\n"+
+			"times")
+
+	notify := flag.Bool(
+		notifyArg,
+		false,
+		"Display a notification when a program fails and will not be retried\n"+
+			"(e.g., because it reached -"+maxRetriesArg+")")
+
+	webhook := flag.String(
+		webhookArg,
+		"",
+		"POST a JSON description of each program's result to the specified\n"+
+			"URL once it succeeds, gives up, or is stopped (e.g.,\n"+
+			"'https://example.com/hook')")
+
+	stdinEvent := flag.Bool(
+		stdinEventArg,
+		false,
+		"Write a JSON object describing the event to each program's stdin\n"+
+			"(see ENVIRONMENT)")
+
+	heartbeat := flag.Duration(
+		heartbeatArg,
+		0,
+		"Log a message at the specified interval while waiting for events,\n"+
+			"which shows that "+appName+" is still running (0 means never)")
+
+	timeout := flag.Duration(
+		timeoutArg,
+		defaultExecTimeout,
+		"The maximum amount of time a wake program may run for each time it\n"+
+			"is executed")
+
+	timeoutAction := flag.String(
+		timeoutActArg,
+		killTimeoutAction,
+		"What to do when a wake program times-out. Supported values are:\n"+
+			"'"+killTimeoutAction+"' - Stop the program and retry it\n"+
+			"'"+warnTimeoutAction+"' - Log a warning and let the program continue running")
+
+	runTimeout := flag.Duration(
+		runTimeoutArg,
+		0,
+		"The maximum amount of time the wake programs executed for a single\n"+
+			"event may take, including retries. Programs that are still running\n"+
+			"or waiting to be retried are then stopped (0 means no maximum)")
+
+	var notifications stringList
+	flag.Var(
+		&notifications,
+		notificationArg,
+		"Observe an additional notification in the format '<name>=<string>'\n"+
+			"(e.g., 'com.apple.screensaver.didstart=-on-screensaver'). Programs\n"+
+			"whose names contain the string are executed when the notification\n"+
+			"is posted. Can be specified multiple times")
+
+	sequential := flag.Bool(
+		sequentialArg,
+		false,
+		"Execute programs one at a time in order of their names, where\n"+
+			"leading numbers are ordered numerically (e.g., '2-x' before '10-x')")
+
+	stopOnError := flag.Bool(
+		stopOnErrorArg,
+		false,
+		"Wait for each program to succeed before executing the next one and\n"+
+			"stop if a program gives up (requires -"+sequentialArg+")")
+
+	concurrency := flag.Int(
+		concurrencyArg,
+		0,
+		"The maximum number of wake programs to execute at the same time\n"+
+			"(0 means unlimited). Programs waiting to be retried do not count\n"+
+			"towards the limit")
+
+	serializeEvents := flag.Bool(
+		serializeArg,
+		false,
+		"Rather than stopping the wake programs that are still running when\n"+
+			"a wake event occurs, wait for them to finish before executing the\n"+
+			"programs again. Wake events that occur while waiting are combined")
+
+	noRestart := flag.Bool(
+		noRestartArg,
+		false,
+		"Rather than stopping the wake programs that are still running when\n"+
+			"a wake event occurs, leave them running and only execute the\n"+
+			"programs that are not running")
+
+	debounce := flag.Duration(
+		debounceArg,
+		0,
+		"Wait for wake events to stop arriving for the specified duration\n"+
+			"before executing programs. Wake events that arrive within the\n"+
+			"duration are combined into a single event (0 means execute\n"+
+			"programs immediately)")
+
+	minInterval := flag.Duration(
+		minIntervalArg,
+		0,
+		"Skip programs that succeeded less than the specified duration ago\n"+
+			"(e.g., '6h'). Useful for expensive programs when the computer\n"+
+			"wakes frequently (0 means never skip)")
+
+	maxOutput := flag.Int64(
+		maxOutputArg,
+		0,
+		"The maximum number of bytes of output to log each time a program\n"+
+			"is executed. Further output is discarded (0 means unlimited)")
+
+	collapseRepeats := flag.Bool(
+		collapseArg,
+		false,
+		"Log consecutive identical lines of a program's output once, followed\n"+
+			"by the number of times the line was repeated")
+
+	logDir := flag.String(
+		logDirArg,
+		"",
+		"Also write each program's output to '<dir>/<program-name>.log'")
+
+	workdir := flag.String(
+		workdirArg,
+		"",
+		"The working directory of the programs. Defaults to the directory\n"+
+			"containing each program")
+
+	cleanEnv := flag.Bool(
+		cleanEnvArg,
+		false,
+		"Rather than inheriting the environment, execute programs with only\n"+
+			"PATH, HOME, USER, the WAKED_* variables, and the variables specified\n"+
+			"by -"+envArg)
+
+	var envVars stringList
+	flag.Var(
+		&envVars,
+		envArg,
+		"Set an environment variable for the programs in the format\n"+
+			"'<name>=<value>' (e.g., 'BACKUP_HOST=nas.local'). Can be specified\n"+
+			"multiple times")
+
+	dirRetry := flag.Duration(
+		dirRetryArg,
+		0,
+		"Keep trying to read directory-path for up to the specified duration\n"+
+			"if it cannot be read when a wake event occurs (e.g., because it is\n"+
+			"on a network share that is not mounted yet). Other events are not\n"+
+			"handled while retrying (0 means do not retry)")
+
+	stateDir := flag.String(
+		stateDirArg,
+		defaultStateDir(),
+		"The directory in which each program's last execution time and status\n"+
+			"are persisted in '"+stateFileName+"' so that they survive restarts.\n"+
+			"Specify an empty string to keep them in memory only")
+
+	recursive := flag.Bool(
+		recursiveArg,
+		false,
+		"Also execute programs found in subdirectories of directory-path")
+
+	followSymlinks := flag.Bool(
+		followLinksArg,
+		false,
+		"Follow symbolic links to directories (requires -"+recursiveArg+")")
+
+	minAge := flag.Duration(
+		minAgeArg,
+		0,
+		"Do not execute programs that were modified less than the specified\n"+
+			"duration ago (e.g., '10s'), which prevents executing programs that\n"+
+			"are still being copied (0 means always execute them)")
+
+	var ignore stringList
+	flag.Var(
+		&ignore,
+		ignoreArg,
+		"Ignore files whose names match the specified glob pattern\n"+
+			"(e.g., '*.txt'). Can be specified multiple times")
+
+	var only stringList
+	flag.Var(
+		&only,
+		onlyArg,
+		"Only execute programs whose names match one of the specified\n"+
+			"comma-separated names or glob patterns (e.g., 'backup.sh,sync-*').\n"+
+			"Can be specified multiple times")
+
+	var skip stringList
+	flag.Var(
+		&skip,
+		skipArg,
+		"Do not execute programs whose names match one of the specified\n"+
+			"comma-separated names or glob patterns (e.g., 'backup.sh,sync-*').\n"+
+			"Can be specified multiple times")
+
+	strictPerms := flag.Bool(
+		strictPermsArg,
+		false,
+		"Skip programs that are group- or world-writable, or whose directory\n"+
+			"is (recommended when running as root)")
+
+	var interpreters stringList
+	flag.Var(
+		&interpreters,
+		interpreterArg,
+		"Execute files with the specified extension using an interpreter in\n"+
+			"the format '<extension>=<interpreter>' (e.g., '.py=/usr/bin/python3').\n"+
+			"Such files do not need to be executable. Can be specified multiple\n"+
+			"times")
+
+	maxUptime := flag.Duration(
+		maxUptimeArg,
+		0,
+		"Exit after running for the specified duration, as if SIGTERM was\n"+
+			"received, so that launchd can start a fresh instance (0 means\n"+
+			"run forever)")
+
+	loginShell := flag.Bool(
+		loginShellArg,
+		false,
+		"Execute programs using the user's login shell ('$SHELL -l') so that\n"+
+			"its profile is sourced (e.g., to add Homebrew to PATH)")
+
+	var exeArgs stringList
+	flag.Var(
+		&exeArgs,
+		argsArg,
+		"An argument to pass to every program (e.g., '--verbose'). Can be\n"+
+			"specified multiple times to pass multiple arguments")
+
+	termGrace := flag.Duration(
+		termGraceArg,
+		10*time.Second,
+		"The amount of time a program has to exit after receiving SIGTERM\n"+
+			"(or -"+killSignalArg+") before it is killed with SIGKILL")
+
+	killSignal := flag.String(
+		killSignalArg,
+		"SIGTERM",
+		"The signal (e.g., 'SIGUSR1') sent to programs that are stopped\n"+
+			"(e.g., because they timed-out or a new wake event occurred)")
+
+	signalOnWake := flag.String(
+		signalOnWakeArg,
+		"",
+		"Send the specified signal (e.g., 'SIGHUP') to programs that are\n"+
+			"still running when a wake event occurs rather than stopping and\n"+
+			"re-executing them")
+
+	unlockOnly := flag.Bool(
+		unlockOnlyArg,
+		false,
+		"Treat every wake program as if its name contained '"+needsUnlockStr+"'.\n"+
+			"Wake programs are then only executed once the screen is unlocked")
+
+	minSleep := flag.Duration(
+		minSleepArg,
+		0,
+		"Do not execute wake programs if the computer was asleep for less\n"+
+			"than the specified duration (0 means always execute them)")
+
+	configPath := flag.String(
+		configArg,
+		"",
+		"Read options from the specified TOML file (see CONFIG FILE).\n"+
+			"Options specified on the command line override it")
+
+	runAs := flag.String(
+		runAsArg,
+		"",
+		"Execute programs as the specified user rather than as the user\n"+
+			"running "+appName+" (requires root)")
+
 	flag.Parse()
 
 	if *help {
@@ -79,281 +904,2191 @@ func mainWtihError() error {
 		os.Exit(1)
 	}
 
-	ctx, cancelFn := signal.NotifyContext(
-		context.Background(),
-		syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	if *version {
+		os.Stdout.WriteString(versionInfo())
+
+		os.Exit(0)
+	}
+
+	// The job reads the config file itself, which means
+	// its arguments must not include the options set by
+	// the config file.
+	var jobArgs []string
+
+	if *install {
+		var err error
+
+		jobArgs, err = installArgs(flag.CommandLine)
+		if err != nil {
+			return err
+		}
+	}
+
+	var programConfigs map[string]*exeConfig
+
+	if *configPath != "" {
+		expanded, err := expandPath(*configPath)
+		if err != nil {
+			return err
+		}
+
+		programConfigs, err = loadConfigFile(expanded, flag.CommandLine)
+		if err != nil {
+			return err
+		}
+	}
+
+	if *verbose {
+		minLogLevel = debugLevel
+	}
+
+	err := setupLogging(*logTarget, *logFile)
+	if err != nil {
+		return err
+	}
+
+	if *printStatusAndExit {
+		return printStatus(*statusSocket, os.Stdout)
+	}
+
+	if *uninstall {
+		return uninstallLaunchdJob(*system)
+	}
+
+	ctx, cancelFn := notifyStopSignals(context.Background())
 	defer cancelFn()
 
-	go func() {
-		<-ctx.Done()
+	if *maxUptime < 0 {
+		return errors.New("maximum uptime cannot be negative")
+	}
 
-		log.Fatalf("recieved signal - %s", ctx.Err())
-	}()
+	// Simulated events are handled once, so there is
+	// nothing to restart.
+	if *maxUptime > 0 && *simulate == "" && !*once {
+		var cancelUptimeFn context.CancelCauseFunc
+
+		// Unlike a deadline, cancelling the context makes
+		// it the same as receiving SIGTERM, which means the
+		// programs are reported as stopped.
+		ctx, cancelUptimeFn = context.WithCancelCause(ctx)
+		defer cancelUptimeFn(nil)
+
+		uptimeTimer := time.AfterFunc(*maxUptime, func() {
+			cancelUptimeFn(fmt.Errorf("reached maximum uptime of %s", *maxUptime))
+		})
+		defer uptimeTimer.Stop()
+	}
 
-	exesDir := flag.Arg(0)
-	if exesDir == "" {
-		exesDir = defaultExesDirPath
+	exesDirs := append([]string(dirs), flag.Args()...)
+	if len(exesDirs) == 0 && *manifest == "" {
+		exesDirs = []string{defaultExesDirPath}
 	}
 
 	ctl := execCtl{
-		ctx:     ctx,
-		exesDir: exesDir,
+		ctx:              ctx,
+		exesDirs:         exesDirs,
+		manifest:         *manifest,
+		sleepTimeout:     *sleepTimeout,
+		sequential:       *sequential,
+		serializeEvents:  *serializeEvents,
+		noRestart:        *noRestart,
+		stopOnError:      *stopOnError,
+		concurrency:      *concurrency,
+		debounce:         *debounce,
+		logDir:           *logDir,
+		workdir:          *workdir,
+		cleanEnv:         *cleanEnv,
+		envVars:          envVars,
+		stateDir:         *stateDir,
+		dirRetry:         *dirRetry,
+		recursive:        *recursive,
+		followSymlinks:   *followSymlinks,
+		ignore:           ignore,
+		onlyArgs:         only,
+		skipArgs:         skip,
+		strictPerms:      *strictPerms,
+		minAge:           *minAge,
+		interpreterArgs:  interpreters,
+		loginShell:       *loginShell,
+		termGrace:        *termGrace,
+		signalOnWakeArg:  *signalOnWake,
+		killSignalArg:    *killSignal,
+		unlockOnly:       *unlockOnly,
+		timeoutAction:    *timeoutAction,
+		minSleep:         *minSleep,
+		dryRun:           *dryRun,
+		runAs:            *runAs,
+		noRetryCodesArg:  *noRetryCodes,
+		runHook:          *runHook,
+		networkHost:      *networkHost,
+		notify:           *notify,
+		webhook:          *webhook,
+		stdinEvent:       *stdinEvent,
+		notificationArgs: notifications,
+		programConfigs:   programConfigs,
+		heartbeat:        *heartbeat,
+		runTimeout:       *runTimeout,
+		maxOutput:        *maxOutput,
+		collapseRepeats:  *collapseRepeats,
+		backoff: backoff{
+			kind:   *backoffKind,
+			max:    *maxInterval,
+			jitter: *jitter,
+		},
+		defaults: exeInfo{
+			timeout:       *timeout,
+			retryInterval: defaultRetryInterval,
+			minInterval:   *minInterval,
+			maxRetries:    *maxRetries,
+			args:          exeArgs,
+			waitFor:       waitFor,
+		},
 	}
 
-	err := ctl.validate()
+	err = ctl.validate()
 	if err != nil {
 		return err
 	}
 
-	// Here we use the NSNotificationCenter via the shared workspace
-	// to receive NSWorkspaceDidWakeNotification events.
-	//
-	// In order to do this, we need to execute the macOS app entrypoint
-	// code. If we do not do this, we never get events. Stackoverflow
-	// user Hans Passant notes:
-	//
-	//   "A console mode app for example, that won't work,
-	//   CFRunLoopRun() is crucial to allow the OS to make
-	//   callbacks."
-	//   - https://stackoverflow.com/questions/64009042/not-receiving-nsworkspacewillsleepnotification-from-notificationcenter-using-c-s#comment113219829_64009042
-	//
-	// Examples:
-	// https://forums.developer.apple.com/forums/thread/26430
-	// https://developer.apple.com/documentation/foundation/nsnotificationcenter/1411723-addobserverforname?language=objc
-	//
-	macos.RunApp(func(appkit.Application, *appkit.ApplicationDelegate) {
-		notifCenter := appkit.Workspace_SharedWorkspace().NotificationCenter()
-
-		queue := foundation.OperationQueue_MainQueue()
-
-		notifCenter.AddObserverForNameObjectQueueUsingBlock(
-			foundation.NotificationName("NSWorkspaceDidWakeNotification"),
-			nil,
-			queue,
-			ctl.onEvent,
-		)
-	})
+	if *install {
+		return ctl.install(*system, *installLog, jobArgs)
+	}
 
-	return nil
-}
+	ctl.checkExes()
 
-type execCtl struct {
-	ctx            context.Context
-	exesDir        string
-	mu             sync.Mutex
-	stopChildrenFn func(error)
-}
+	if *once {
+		*simulate = "wake"
+	}
 
-func (o *execCtl) validate() error {
-	if o.exesDir == "" {
-		return errors.New("please specify a directory containing executables to execute")
+	var source eventSource
+
+	if *simulate != "" {
+		source, err = newSimulatedSource(*simulate)
+		if err != nil {
+			return err
+		}
+	} else {
+		source = newEventSource(ctl.notificationNames())
 	}
 
-	o.exesDir = filepath.Clean(o.exesDir)
+	// Simulated events do not register observers, so there
+	// is no need to prevent them from running alongside
+	// another instance.
+	if *simulate == "" && *lockFilePath != "" {
+		lock, err := acquireLockFile(*lockFilePath)
+		if err != nil {
+			return err
+		}
+		defer lock.Close()
+	}
 
-	if o.ctx == nil {
-		return errors.New("context is nil")
+	if *simulate == "" && *statusSocket != "" {
+		statusListener, err := ctl.serveStatus(*statusSocket)
+		if err != nil {
+			return err
+		}
+		defer statusListener.Close()
 	}
 
-	return nil
-}
+	if *simulate == "" && *metricsAddr != "" {
+		metricsListener, err := ctl.serveMetrics(*metricsAddr)
+		if err != nil {
+			return err
+		}
+		defer metricsListener.Close()
+	}
 
-func (o *execCtl) onEvent(foundation.Notification) {
-	o.mu.Lock()
-	defer o.mu.Unlock()
+	events := make(chan event)
 
-	if o.stopChildrenFn != nil {
-		o.stopChildrenFn(errors.New("recieved new wake event"))
+	if *simulate == "" {
+		go notifyReloads(ctx, events)
 
-		o.stopChildrenFn = nil
+		if ctl.heartbeat > 0 {
+			go ctl.logHeartbeats(ctx)
+		}
 	}
 
-	infos, err := os.ReadDir(o.exesDir)
+	// Simulated events run in place of the start programs.
+	if *simulate == "" {
+		ctl.onEvent(newEvent(startEventName))
+	}
+
+	handlerDone := make(chan struct{})
+
+	go func() {
+		ctl.handleEvents(events)
+		close(handlerDone)
+	}()
+
+	err = source.Notify(ctx, events)
+
+	close(events)
+	<-handlerDone
+
 	if err != nil {
-		log.Printf("failed to read executables directory %q - %s",
-			o.exesDir, err)
+		return err
+	}
 
-		return
+	if ctx.Err() != nil {
+		return ctl.shutdown()
 	}
 
-	ctx, cancelFn := context.WithCancelCause(o.ctx)
-	o.stopChildrenFn = cancelFn
+	return ctl.wait()
+}
 
-	for _, info := range infos {
-		if info.IsDir() {
-			continue
+// versionInfo returns the program's version, the commit it
+// was built from, and the Go version used to build it.
+func versionInfo() string {
+	version := "unknown"
+	commit := "unknown"
+	goVersion := runtime.Version()
+
+	buildInfo, ok := debug.ReadBuildInfo()
+	if ok {
+		if buildInfo.Main.Version != "" {
+			version = buildInfo.Main.Version
 		}
 
-		exePath := filepath.Join(o.exesDir, info.Name())
+		goVersion = buildInfo.GoVersion
+
+		modified := false
+
+		for _, setting := range buildInfo.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				commit = setting.Value
+			case "vcs.modified":
+				modified = setting.Value == "true"
+			}
+		}
 
-		go execRetry(ctx, exePath)
+		if modified {
+			commit += " (modified)"
+		}
 	}
+
+	return "version: " + version + "\n" +
+		"commit: " + commit + "\n" +
+		"go: " + goVersion + "\n"
 }
 
-func execRetry(ctx context.Context, exePath string) error {
-	for {
-		_, err := os.Stat(exePath)
-		if err != nil {
-			log.Printf("[%s] no longer stat'able - %s", exePath, err)
+type execCtl struct {
+	ctx              context.Context
+	exesDirs         []string
+	manifest         string
+	sleepTimeout     time.Duration
+	sequential       bool
+	serializeEvents  bool
+	noRestart        bool
+	stopOnError      bool
+	concurrency      int
+	debounce         time.Duration
+	logDir           string
+	workdir          string
+	cleanEnv         bool
+	envVars          []string
+	recursive        bool
+	followSymlinks   bool
+	ignore           []string
+	onlyArgs         []string
+	only             []string
+	skipArgs         []string
+	skip             []string
+	strictPerms      bool
+	minAge           time.Duration
+	interpreterArgs  []string
+	interpreters     map[string][]string
+	loginShell       bool
+	loginShellPath   string
+	termGrace        time.Duration
+	signalOnWakeArg  string
+	killSignalArg    string
+	unlockOnly       bool
+	timeoutAction    string
+	minSleep         time.Duration
+	dryRun           bool
+	runAs            string
+	runAsCred        *syscall.Credential
+	runAsEnv         []string
+	noRetryCodesArg  string
+	noRetryCodes     map[int]struct{}
+	runHook          string
+	networkHost      string
+	notify           bool
+	webhook          string
+	stdinEvent       bool
+	notificationArgs []string
+	notifications    map[string]string
+	programConfigs   map[string]*exeConfig
+	heartbeat        time.Duration
+	runTimeout       time.Duration
+	maxOutput        int64
+	collapseRepeats  bool
+	wakeSignal       syscall.Signal
+	childrenCtx      context.Context
+	childrenMu       sync.Mutex
+	children         map[string]*child
+	lastStarts       map[string]time.Time
+	lastSuccesses    map[string]time.Time
+	backoff          backoff
+	defaults         exeInfo
+	slots            chan struct{}
+	groupsMu         sync.Mutex
+	groupSlots       map[string]chan struct{}
+	mu               sync.Mutex
+	stopChildrenFn   func(error)
+	lastSleep        time.Time
+	lastEventsMu     sync.Mutex
+	lastEvents       map[string]time.Time
+	lastRun          *execRun
+	lastWakeRun      *execRun
+	numWakeExes      atomic.Int64
+	foundWakeExes    atomic.Bool
+	queuedWake       *event
+	execWg           sync.WaitGroup
+	webhookWg        sync.WaitGroup
+	debounceTimer    *time.Timer
+	pendingWake      *event
+	metrics          metrics
+	clock            clock
+	stateDir         string
+	dirRetry         time.Duration
+	state            *stateFile
+	lockStateMu      sync.Mutex
+	lockState        lockState
+}
 
-			return err
+func (o *execCtl) validate() error {
+	if len(o.exesDirs) == 0 && o.manifest == "" {
+		return errors.New("please specify a directory containing executables to execute")
+	}
+
+	if o.manifest != "" {
+		manifest, err := expandPath(o.manifest)
+		if err != nil {
+			return fmt.Errorf("invalid manifest path - %w", err)
 		}
 
-		err = execOnce(ctx, exePath)
-		if err == nil {
-			return nil
+		o.manifest, err = filepath.Abs(manifest)
+		if err != nil {
+			return fmt.Errorf("failed to get absolute manifest path - %w", err)
 		}
 
-		select {
-		case <-ctx.Done():
-			log.Printf("[%s] giving up - %s", ctx.Err())
+		_, err = os.Stat(o.manifest)
+		if err != nil {
+			return fmt.Errorf("failed to stat manifest - %w", err)
+		}
+	}
 
-			return ctx.Err()
-		default:
+	for i, exesDir := range o.exesDirs {
+		if exesDir == "" {
+			return errors.New("executables directory path cannot be empty")
 		}
 
-		waitFor := 10 * time.Second
+		exesDir, err := expandPath(exesDir)
+		if err != nil {
+			return fmt.Errorf("invalid executables directory path - %w", err)
+		}
 
-		if errors.Is(err, screenLockedErr) {
-			waitFor = 5 * time.Second
+		// Programs are executed in their directory,
+		// which means their paths must not be relative
+		// to waked's working directory.
+		exesDir, err = filepath.Abs(exesDir)
+		if err != nil {
+			return fmt.Errorf("failed to get absolute executables directory path - %w", err)
 		}
 
-		log.Printf("[%s] exec failed, will retry in %s - %s",
-			exePath, waitFor.String(), err)
+		if isGlob(exesDir) {
+			// Files that match the pattern may be
+			// added later.
+			matches, err := filepath.Glob(exesDir)
+			if err != nil {
+				return fmt.Errorf("invalid executables glob pattern %q - %w", exesDir, err)
+			}
 
-		select {
-		case <-ctx.Done():
-			log.Printf("[%s] giving up - %s", ctx.Err())
+			if len(matches) == 0 {
+				warnf("executables glob pattern %q does not match any files", exesDir)
+			}
+
+			o.exesDirs[i] = exesDir
 
-			return ctx.Err()
-		case <-time.After(waitFor):
 			continue
 		}
-	}
-}
 
-var screenLockedErr = errors.New("screen is locked")
+		info, err := os.Stat(exesDir)
+		if err != nil {
+			return fmt.Errorf("failed to stat executables directory - %w", err)
+		}
 
-func execOnce(ctx context.Context, exePath string) error {
-	if strings.Contains(filepath.Base(exePath), needsUnlockStr) {
-		isLocked, err := checkIfLocked(ctx)
-		switch {
-		case isLocked:
-			return screenLockedErr
-		case err != nil:
-			log.Printf("[warn] failed to determine if screen is locked - %s", err)
+		// Otherwise, reading the directory would fail
+		// for every event.
+		if !info.IsDir() {
+			return fmt.Errorf("executables directory %q is not a directory (use -%s to execute individual files)",
+				exesDir, manifestArg)
 		}
+
+		o.exesDirs[i] = exesDir
 	}
 
-	ctx, cancelFn := context.WithTimeoutCause(
-		ctx,
-		10*time.Minute,
-		errors.New("timed-out waiting for child process to exit"))
-	defer cancelFn()
+	if o.ctx == nil {
+		return errors.New("context is nil")
+	}
 
-	exe := exec.CommandContext(ctx, exePath)
+	if o.clock == nil {
+		o.clock = realClock{}
+	}
 
-	stderr := newExeLogger(exePath)
-	defer stderr.Close()
+	if o.sleepTimeout <= 0 {
+		return errors.New("sleep timeout must be greater than zero")
+	}
 
-	stdout := newExeLogger(exePath)
-	defer stdout.Close()
+	err := o.backoff.validate()
+	if err != nil {
+		return err
+	}
 
-	exe.Stderr = stderr
-	exe.Stdout = stdout
+	if o.defaults.maxRetries < 0 {
+		return errors.New("maximum retries cannot be negative")
+	}
+
+	if o.stopOnError && !o.sequential {
+		return fmt.Errorf("-%s requires -%s", stopOnErrorArg, sequentialArg)
+	}
+
+	switch {
+	case o.concurrency < 0:
+		return errors.New("concurrency cannot be negative")
+	case o.concurrency > 0:
+		o.slots = make(chan struct{}, o.concurrency)
+	}
+
+	if o.debounce < 0 {
+		return errors.New("debounce duration cannot be negative")
+	}
+
+	if o.followSymlinks && !o.recursive {
+		return fmt.Errorf("-%s requires -%s", followLinksArg, recursiveArg)
+	}
+
+	for _, pattern := range o.ignore {
+		_, err := filepath.Match(pattern, "")
+		if err != nil {
+			return fmt.Errorf("invalid ignore pattern: %q - %w", pattern, err)
+		}
+	}
 
-	err := exe.Run()
+	only, err := parseNamePatterns(onlyArg, o.onlyArgs)
 	if err != nil {
-		return fmt.Errorf("exec failed - %w", err)
+		return err
 	}
 
-	return nil
-}
+	o.only = only
 
-func newExeLogger(exePath string) *exeLogger {
-	r, w := io.Pipe()
+	skip, err := parseNamePatterns(skipArg, o.skipArgs)
+	if err != nil {
+		return err
+	}
 
-	l := &exeLogger{
-		exePath: exePath,
-		r:       r,
-		w:       w,
+	o.skip = skip
+
+	if o.defaults.timeout <= 0 {
+		return errors.New("timeout must be greater than zero")
 	}
 
-	go l.loop()
+	if o.runTimeout < 0 {
+		return errors.New("run timeout cannot be negative")
+	}
 
-	return l
-}
+	switch o.timeoutAction {
+	case killTimeoutAction:
+	case warnTimeoutAction:
+		o.defaults.warnOnTimeout = true
+	default:
+		return fmt.Errorf("unknown -%s: %q (supported values are: %s, %s)",
+			timeoutActArg, o.timeoutAction, killTimeoutAction, warnTimeoutAction)
+	}
 
-type exeLogger struct {
-	exePath string
-	r       io.ReadCloser
-	w       io.WriteCloser
-}
+	if o.minAge < 0 {
+		return errors.New("minimum age cannot be negative")
+	}
 
-func (o *exeLogger) Write(b []byte) (int, error) {
-	return o.w.Write(b)
-}
+	if o.dirRetry < 0 {
+		return errors.New("directory retry duration cannot be negative")
+	}
 
-func (o *exeLogger) Close() error {
-	o.r.Close()
-	o.w.Close()
+	if o.defaults.minInterval < 0 {
+		return errors.New("minimum interval cannot be negative")
+	}
 
-	return nil
-}
+	if o.maxOutput < 0 {
+		return errors.New("maximum output size cannot be negative")
+	}
 
-func (o *exeLogger) loop() {
-	scanner := bufio.NewScanner(o.r)
+	if o.heartbeat < 0 {
+		return errors.New("heartbeat interval cannot be negative")
+	}
 
-	for scanner.Scan() {
-		log.Printf("[%s] %s", o.exePath, scanner.Text())
+	if o.minSleep < 0 {
+		return errors.New("minimum sleep duration cannot be negative")
 	}
-}
 
-// Based on work by Joel Bruner:
-// https://stackoverflow.com/a/66723000
-//
-// We could use Go's XML parser here, but I do not feel
-// like dealing with Apple's plist format.
-func checkIfLocked(ctx context.Context) (bool, error) {
-	// /usr/sbin/ioreg -n Root -d1 -a
-	ioreg := exec.CommandContext(
-		ctx,
-		"/usr/sbin/ioreg",
-		"-n",
-		"Root",
-		"-d1",
-		"-a")
+	if o.termGrace < 0 {
+		return errors.New("termination grace period cannot be negative")
+	}
+
+	if o.signalOnWakeArg != "" {
+		o.wakeSignal, err = parseSignal(o.signalOnWakeArg)
+		if err != nil {
+			return fmt.Errorf("invalid -%s value - %w", signalOnWakeArg, err)
+		}
+	}
 
-	ioregOutput, err := ioreg.CombinedOutput()
+	o.defaults.killSignal, err = parseSignal(o.killSignalArg)
 	if err != nil {
-		return false, fmt.Errorf("ioreg failed (%v) - %w - output: %q",
-			ioreg.Args, err, ioregOutput)
+		return fmt.Errorf("invalid -%s value - %w", killSignalArg, err)
 	}
 
-	const coreGraphicsParam = "CGSSessionScreenIsLocked"
+	if o.serializeEvents && o.signalOnWakeArg != "" {
+		return fmt.Errorf("-%s and -%s cannot be used together",
+			serializeArg, signalOnWakeArg)
+	}
 
-	if !bytes.Contains(ioregOutput, []byte(coreGraphicsParam)) {
-		return false, nil
+	if o.noRestart && (o.serializeEvents || o.signalOnWakeArg != "") {
+		return fmt.Errorf("-%s cannot be used with -%s or -%s",
+			noRestartArg, serializeArg, signalOnWakeArg)
 	}
 
-	// /usr/bin/plutil -extract 'IOConsoleUsers.0.CGSSessionScreenIsLocked' raw -
-	plutil := exec.CommandContext(
-		ctx,
-		"/usr/bin/plutil",
-		"-extract",
-		"IOConsoleUsers.0."+coreGraphicsParam,
-		"raw",
-		"-")
+	if o.noRetryCodesArg != "" {
+		o.noRetryCodes = make(map[int]struct{})
 
-	plutil.Stdin = bytes.NewReader(ioregOutput)
+		for _, codeStr := range strings.Split(o.noRetryCodesArg, ",") {
+			code, err := strconv.Atoi(strings.TrimSpace(codeStr))
+			if err != nil {
+				return fmt.Errorf("invalid -%s value - %w", noRetryCodesArg, err)
+			}
 
-	plutilOutput, err := plutil.CombinedOutput()
-	if err != nil {
-		return false, fmt.Errorf("plutil (%v) failed - %w - output: %q",
-			plutil.Args, err, plutilOutput)
+			if code <= 0 || code > 255 {
+				return fmt.Errorf("-%s exit statuses must be between 1 and 255 (%d)",
+					noRetryCodesArg, code)
+			}
+
+			o.noRetryCodes[code] = struct{}{}
+		}
+	}
+
+	if o.networkHost != "" {
+		_, _, err := net.SplitHostPort(o.networkHost)
+		if err != nil {
+			return fmt.Errorf("invalid -%s value - %w", networkHostArg, err)
+		}
+	}
+
+	if o.webhook != "" {
+		webhookURL, err := url.Parse(o.webhook)
+		if err != nil {
+			return fmt.Errorf("invalid -%s value - %w", webhookArg, err)
+		}
+
+		if webhookURL.Scheme != "http" && webhookURL.Scheme != "https" {
+			return fmt.Errorf("-%s must be an http or https URL (%q)", webhookArg, o.webhook)
+		}
+	}
+
+	for _, addr := range o.defaults.waitFor {
+		_, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return fmt.Errorf("invalid -%s value - %w", waitForArg, err)
+		}
+	}
+
+	o.notifications = make(map[string]string)
+
+	for _, arg := range o.notificationArgs {
+		name, nameStr, err := parseKeyValue(arg)
+		if err != nil {
+			return fmt.Errorf("invalid -%s value - %w", notificationArg, err)
+		}
+
+		switch name {
+		case wakeNotification, sleepNotification, lockNotification, unlockNotification:
+			return fmt.Errorf("notification %q is already observed", name)
+		}
+
+		if nameStr == "" {
+			return fmt.Errorf("the string for notification %q cannot be empty", name)
+		}
+
+		o.notifications[name] = nameStr
+	}
+
+	if o.runAs != "" {
+		err := o.lookupRunAs()
+		if err != nil {
+			return fmt.Errorf("invalid -%s value - %w", runAsArg, err)
+		}
+	}
+
+	for _, envVar := range o.envVars {
+		name, _, err := parseKeyValue(envVar)
+		if err != nil {
+			return fmt.Errorf("invalid -%s value - %w", envArg, err)
+		}
+
+		if strings.HasPrefix(name, "WAKED_") {
+			return fmt.Errorf("-%s cannot set %s, it is set by %s", envArg, name, appName)
+		}
+	}
+
+	o.interpreters = make(map[string][]string)
+
+	for ext, interpreterAndArgs := range defaultInterpreters {
+		o.interpreters[ext] = interpreterAndArgs
+	}
+
+	for _, arg := range o.interpreterArgs {
+		ext, interpreter, err := parseKeyValue(arg)
+		if err != nil {
+			return fmt.Errorf("invalid interpreter mapping - %w", err)
+		}
+
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+
+		interpreterAndArgs := strings.Fields(interpreter)
+		if len(interpreterAndArgs) == 0 {
+			return fmt.Errorf("interpreter for extension %q is empty", ext)
+		}
+
+		o.interpreters[ext] = interpreterAndArgs
+	}
+
+	if o.loginShell {
+		o.loginShellPath = os.Getenv("SHELL")
+
+		// launchd does not set SHELL.
+		if o.loginShellPath == "" {
+			o.loginShellPath = defaultLoginShell()
+		}
 	}
 
-	plutilOutput = bytes.TrimSpace(plutilOutput)
+	if o.workdir != "" {
+		workdir, err := expandPath(o.workdir)
+		if err != nil {
+			return fmt.Errorf("invalid working directory path - %w", err)
+		}
+
+		o.workdir, err = filepath.Abs(workdir)
+		if err != nil {
+			return fmt.Errorf("failed to get absolute working directory path - %w", err)
+		}
+
+		info, err := os.Stat(o.workdir)
+		if err != nil {
+			return fmt.Errorf("failed to stat working directory - %w", err)
+		}
+
+		if !info.IsDir() {
+			return fmt.Errorf("working directory %q is not a directory", o.workdir)
+		}
+	}
+
+	if o.logDir != "" {
+		o.logDir = filepath.Clean(o.logDir)
+
+		err := os.MkdirAll(o.logDir, 0o755)
+		if err != nil {
+			return fmt.Errorf("failed to create log directory - %w", err)
+		}
+	}
+
+	var stateFilePath string
+
+	if o.stateDir != "" {
+		stateFilePath = filepath.Join(o.stateDir, stateFileName)
+	}
+
+	state, err := loadStateFile(stateFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to load state file - %w", err)
+	}
+
+	o.state = state
+	o.lastSuccesses = state.lastSuccesses()
+
+	return nil
+}
+
+// defaultLoginShell returns the operating system's default
+// login shell.
+func defaultLoginShell() string {
+	if runtime.GOOS == "darwin" {
+		return "/bin/zsh"
+	}
+
+	return "/bin/sh"
+}
+
+// lookupRunAs looks up the user specified by o.runAs and sets
+// the credentials and environment variables used to execute
+// programs as that user.
+func (o *execCtl) lookupRunAs() error {
+	u, err := user.Lookup(o.runAs)
+	if err != nil {
+		var unknownUserErr user.UnknownUserError
+		if errors.As(err, &unknownUserErr) {
+			return fmt.Errorf("user %q does not exist", o.runAs)
+		}
+
+		return fmt.Errorf("failed to lookup user %q - %w", o.runAs, err)
+	}
+
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return fmt.Errorf("failed to parse uid of user %q - %w", o.runAs, err)
+	}
+
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return fmt.Errorf("failed to parse gid of user %q - %w", o.runAs, err)
+	}
+
+	if os.Geteuid() != 0 && uint64(os.Geteuid()) != uid {
+		return fmt.Errorf("executing programs as %q requires root", o.runAs)
+	}
+
+	var groups []uint32
+
+	groupIDs, err := u.GroupIds()
+	if err != nil {
+		warnf("failed to lookup groups of user %q - %s", o.runAs, err)
+	}
+
+	for _, groupID := range groupIDs {
+		group, err := strconv.ParseUint(groupID, 10, 32)
+		if err != nil {
+			continue
+		}
+
+		groups = append(groups, uint32(group))
+	}
+
+	o.runAsCred = &syscall.Credential{
+		Uid:    uint32(uid),
+		Gid:    uint32(gid),
+		Groups: groups,
+	}
+
+	// Later values take precedence over the inherited ones.
+	o.runAsEnv = []string{
+		"HOME=" + u.HomeDir,
+		"USER=" + u.Username,
+		"LOGNAME=" + u.Username,
+	}
+
+	return nil
+}
+
+// notificationNames returns the names of the notifications
+// specified using -notification.
+func (o *execCtl) notificationNames() []string {
+	var names []string
+
+	for name := range o.notifications {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// logHeartbeats logs a message every o.heartbeat until ctx is done.
+// The number of wake programs is the number found by the last wake
+// event. Rescanning the directories for each heartbeat would repeat
+// their warnings (e.g., about invalid sidecar files).
+func (o *execCtl) logHeartbeats(ctx context.Context) {
+	ticker := time.NewTicker(o.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// The directories are only scanned if no wake
+		// event has occurred yet.
+		if !o.foundWakeExes.Load() {
+			exes, _ := o.findExes(newEvent(wakeNotification))
+			o.setNumWakeExes(len(exes))
+		}
+
+		o.childrenMu.Lock()
+		numRunning := len(o.children)
+		o.childrenMu.Unlock()
+
+		infof("waiting for events - %d wake program(s) configured, %d running",
+			o.numWakeExes.Load(), numRunning)
+	}
+}
+
+// setNumWakeExes records the number of wake programs that
+// logHeartbeats reports.
+func (o *execCtl) setNumWakeExes(n int) {
+	o.numWakeExes.Store(int64(n))
+	o.foundWakeExes.Store(true)
+}
+
+// handleEvents handles events received from c until c is closed.
+// Each event is acknowledged once it has been handled.
+func (o *execCtl) handleEvents(c <-chan event) {
+	for ev := range c {
+		o.onEvent(ev)
+
+		ev.ack()
+	}
+}
+
+// onEvent handles an event. All events are handled while
+// holding o.mu, which means a wake event waits for any
+// pending sleep run to finish.
+func (o *execCtl) onEvent(ev event) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.metrics.addEvent(ev.name)
+	o.setLastEvent(ev)
+
+	switch {
+	case ev.name == sleepNotification:
+		o.lastSleep = ev.time
+
+		// A pending wake event is stale at this point.
+		if o.debounceTimer != nil {
+			o.debounceTimer.Stop()
+			o.pendingWake = nil
+		}
+
+		o.queuedWake = nil
+
+		o.lastRun = o.onSleep(ev)
+	case o.isAdditiveEvent(ev.name):
+		o.lastRun = o.onWake(ev)
+	default:
+		if !o.lastSleep.IsZero() {
+			ev.sinceSleep = ev.time.Sub(o.lastSleep)
+		}
+
+		// The sleep duration is unknown if the preceding
+		// sleep event was not observed.
+		if o.minSleep > 0 && ev.sinceSleep > 0 && ev.sinceSleep < o.minSleep {
+			infof("skipping wake event - computer was asleep for %s, which is less than %s",
+				ev.sinceSleep.Round(time.Second), o.minSleep)
+
+			return
+		}
+
+		if o.debounce > 0 {
+			o.debounceWake(ev)
+
+			return
+		}
+
+		o.startWake(ev)
+	}
+}
+
+// startWake executes the wake programs for ev. If o.serializeEvents
+// is true and the programs executed for the previous wake event
+// are still running, ev is queued until they finish instead.
+//
+// The caller must hold o.mu.
+func (o *execCtl) startWake(ev event) {
+	if o.serializeEvents && o.lastWakeRun != nil && !o.lastWakeRun.isSettled() {
+		o.queueWake(ev)
+
+		return
+	}
+
+	o.lastRun = o.onWake(ev)
+	o.lastWakeRun = o.lastRun
+}
+
+// queueWake queues ev until the programs executed for the previous
+// wake event finish. Wake events that arrive while an event is
+// queued are combined with it. The first queued event is the one
+// passed to the programs.
+//
+// The caller must hold o.mu.
+func (o *execCtl) queueWake(ev event) {
+	if o.queuedWake != nil {
+		infof("combining %s event with the queued wake event", ev.name)
+
+		return
+	}
+
+	infof("queuing %s event until the previous wake programs finish", ev.name)
+
+	o.queuedWake = &ev
+
+	run := o.lastWakeRun
+
+	go func() {
+		<-run.settled
+
+		o.mu.Lock()
+		defer o.mu.Unlock()
+
+		// The queued event may have been dropped by
+		// a sleep event or already started by another
+		// instance of this goroutine.
+		if o.queuedWake == nil {
+			return
+		}
+
+		ev := *o.queuedWake
+		o.queuedWake = nil
+
+		o.startWake(ev)
+	}()
+}
+
+// debounceWake delays the execution of wake programs until
+// no wake events have arrived for o.debounce. The first wake
+// event in the window is the one passed to the programs.
+//
+// The caller must hold o.mu.
+func (o *execCtl) debounceWake(ev event) {
+	if o.pendingWake == nil {
+		o.pendingWake = &ev
+	}
+
+	if o.debounceTimer == nil {
+		o.debounceTimer = time.AfterFunc(o.debounce, o.onDebounceTimer)
+
+		return
+	}
+
+	o.debounceTimer.Reset(o.debounce)
+}
+
+func (o *execCtl) onDebounceTimer() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	// The timer may fire after it was reset by a new event
+	// or stopped by a sleep event, in which case there is
+	// nothing to do.
+	if o.pendingWake == nil {
+		return
+	}
+
+	ev := *o.pendingWake
+	o.pendingWake = nil
+
+	o.startWake(ev)
+}
+
+// wait waits for the programs executed for the most recent event
+// to exit. A pending debounced wake event is handled immediately.
+// A non-nil error is returned if any of the programs failed.
+func (o *execCtl) wait() error {
+	o.mu.Lock()
+
+	if o.pendingWake != nil {
+		o.debounceTimer.Stop()
+
+		ev := *o.pendingWake
+		o.pendingWake = nil
+
+		o.lastRun = o.onWake(ev)
+	}
+
+	run := o.lastRun
+
+	o.mu.Unlock()
+
+	if run == nil {
+		return nil
+	}
+
+	err := run.wait()
+
+	o.webhookWg.Wait()
+
+	return err
+}
+
+// shutdown waits for all running programs to exit after o.ctx
+// is done, including those executed for earlier events. Cancelling
+// o.ctx sends SIGTERM to the programs, and then SIGKILL to those
+// that do not exit within o.termGrace, so shutdownGrace past that
+// is plenty. A non-nil error is returned if the programs did not
+// exit in time.
+func (o *execCtl) shutdown() error {
+	infof("shutting down, waiting for programs to exit - %s", context.Cause(o.ctx))
+
+	// Pending and queued wake events are stale at this point.
+	o.mu.Lock()
+
+	if o.debounceTimer != nil {
+		o.debounceTimer.Stop()
+		o.pendingWake = nil
+	}
+
+	o.queuedWake = nil
+
+	o.mu.Unlock()
+
+	exited := make(chan struct{})
+
+	go func() {
+		o.execWg.Wait()
+		o.webhookWg.Wait()
+		close(exited)
+	}()
+
+	timer := time.NewTimer(o.termGrace + shutdownGrace)
+	defer timer.Stop()
+
+	select {
+	case <-exited:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("timed-out waiting for programs to exit after %s",
+			o.termGrace+shutdownGrace)
+	}
+}
+
+// isAdditiveEvent returns true if the event's programs are
+// added to the programs executed for the previous wake event
+// rather than replacing them. This is true of every event
+// other than wake and sleep events.
+func (o *execCtl) isAdditiveEvent(name string) bool {
+	switch name {
+	case reloadEventName, startEventName, unlockNotification, lockNotification:
+		return true
+	}
+
+	_, isCustom := o.notifications[name]
+
+	return isCustom
+}
+
+func (o *execCtl) onWake(ev event) *execRun {
+	isAdditive := o.isAdditiveEvent(ev.name)
+
+	// When signaling children, handling an additive event, or
+	// when o.noRestart is true, the programs from the previous
+	// wake event are left running and share a context with the
+	// programs executed for this event.
+	leaveChildren := (o.wakeSignal != 0 || o.noRestart || isAdditive) && o.stopChildrenFn != nil
+
+	if leaveChildren && o.wakeSignal != 0 && !isAdditive {
+		o.signalChildren(o.wakeSignal)
+	} else if !leaveChildren && o.stopChildrenFn != nil {
+		o.stopChildrenFn(errors.New("received a new wake event"))
+
+		o.stopChildrenFn = nil
+	}
+
+	run := newExecRun(ev)
+	defer o.settleRun(run)
+
+	exes, err := o.findExesWithRetry(ev)
+	if err != nil {
+		errorf("failed to find executables - %s", err)
+
+		run.err = err
+	}
+
+	if ev.name == wakeNotification {
+		o.setNumWakeExes(len(exes))
+	}
+
+	if o.dryRun {
+		logDryRun(ev, exes)
+
+		return run
+	}
+
+	if ev.name == unlockNotification {
+		exes = slices.DeleteFunc(exes, func(exe *exeInfo) bool {
+			if o.startedWithin(exe.path, unlockDedupeWindow) {
+				debugf("[%s] not executing for unlock event - program was started less than %s ago",
+					exe.path, unlockDedupeWindow)
+
+				return true
+			}
+
+			return false
+		})
+	}
+
+	if leaveChildren {
+		exes = slices.DeleteFunc(exes, func(exe *exeInfo) bool {
+			if !o.isChildActive(exe.path) {
+				return false
+			}
+
+			if o.noRestart {
+				infof("[%s] not executing - still running from a previous event (-%s)",
+					exe.path, noRestartArg)
+			}
+
+			return true
+		})
+	} else {
+		o.childrenCtx, o.stopChildrenFn = context.WithCancelCause(o.ctx)
+	}
+
+	ctx := o.childrenCtx
+
+	if o.runTimeout > 0 {
+		var cancelFn context.CancelFunc
+
+		ctx, cancelFn = context.WithTimeoutCause(
+			ctx,
+			o.runTimeout,
+			fmt.Errorf("programs for %s did not finish within %s",
+				ev.name, o.runTimeout))
+
+		go func() {
+			<-run.settled
+			cancelFn()
+		}()
+	}
+
+	graph := newDependencyGraph(exes)
+
+	for _, exe := range graph.cyclic {
+		errorf("[%s] not executing - it is part of or depends on a dependency cycle",
+			exe.path)
+
+		run.record(exe.path, dependencyCycleErr)
+	}
+
+	exes = graph.order
+
+	if o.sequential {
+		o.goExec(run, func() {
+			o.execSequential(ctx, ev, exes, graph, run)
+		})
+
+		return run
+	}
+
+	// The programs in each directory whose config file
+	// specifies sequential are executed like -sequential,
+	// concurrently with the other programs.
+	var sequentialDirs []string
+	sequentialExes := make(map[string][]*exeInfo)
+
+	for _, exe := range exes {
+		if exe.sequentialDir != "" {
+			if _, ok := sequentialExes[exe.sequentialDir]; !ok {
+				sequentialDirs = append(sequentialDirs, exe.sequentialDir)
+			}
+
+			sequentialExes[exe.sequentialDir] = append(sequentialExes[exe.sequentialDir], exe)
+
+			continue
+		}
+
+		o.goExec(run, func() {
+			err := o.execAfterDependencies(ctx, ev, exe, graph, nil)
+			run.record(exe.path, err)
+		})
+	}
+
+	for _, dir := range sequentialDirs {
+		o.goExec(run, func() {
+			o.execSequential(ctx, ev, sequentialExes[dir], graph, run)
+		})
+	}
+
+	return run
+}
+
+// execSequential executes exes one at a time. Unless o.stopOnError
+// is true, the next program is started once the current program's
+// first attempt finishes. The current program's retries then
+// continue in the background.
+func (o *execCtl) execSequential(ctx context.Context, ev event, exes []*exeInfo, graph *dependencyGraph, run *execRun) {
+	for _, exe := range exes {
+		if o.stopOnError {
+			err := o.execAfterDependencies(ctx, ev, exe, graph, nil)
+			run.record(exe.path, err)
+
+			if err != nil && !errors.Is(err, skippedErr) {
+				warnf("[%s] not executing remaining programs because -%s was specified",
+					exe.path, stopOnErrorArg)
+
+				return
+			}
+
+			continue
+		}
+
+		firstAttemptDone := make(chan struct{})
+		onFirstAttempt := sync.OnceFunc(func() { close(firstAttemptDone) })
+
+		o.goExec(run, func() {
+			defer onFirstAttempt()
+
+			err := o.execAfterDependencies(ctx, ev, exe, graph, onFirstAttempt)
+			run.record(exe.path, err)
+		})
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-firstAttemptDone:
+		}
+	}
+}
+
+// goExec calls fn in a new goroutine that is tracked by both
+// run.wg and o.execWg. This allows waiting for the programs
+// executed for a single event, or for all of them.
+func (o *execCtl) goExec(run *execRun, fn func()) {
+	run.wg.Add(1)
+	o.execWg.Add(1)
+
+	go func() {
+		defer o.execWg.Done()
+		defer run.wg.Done()
+
+		fn()
+	}()
+}
+
+// logDryRun logs the programs that would be executed
+// for the event.
+func logDryRun(ev event, exes []*exeInfo) {
+	if len(exes) == 0 {
+		infof("[dry-run] no programs would be executed for %s", ev.name)
+
+		return
+	}
+
+	for _, exe := range exes {
+		name, args := exe.command()
+
+		infof("[dry-run] [%s] would be executed for %s (command: %q, on-unlock: %t, on-ac: %t, timeout: %s)",
+			exe.path, ev.name, append([]string{name}, args...), exe.needsUnlock, exe.needsAC, exe.timeout)
+	}
+}
+
+func newExecRun(ev event) *execRun {
+	return &execRun{
+		ev:      ev,
+		settled: make(chan struct{}),
+	}
+}
+
+// execRun tracks the programs executed for a single event.
+type execRun struct {
+	ev      event
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	results []exeResult
+	failed  []string
+
+	// err is set if the run's programs could not be found.
+	err error
+
+	// settled is closed once the run's programs have exited
+	// and the run has been reported by execCtl.settleRun.
+	settled chan struct{}
+}
+
+// record records the result of executing a program. err is
+// the error returned by execRetry or execOnce. Programs that
+// were stopped (e.g., because waked is exiting) did not fail.
+func (o *execRun) record(exePath string, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	result := newExeResult(exePath, err)
+
+	o.results = append(o.results, result)
+
+	if result.Status == failedStatus {
+		o.failed = append(o.failed, exePath)
+	}
+}
+
+// wait waits for the run's programs to exit. A non-nil error
+// is returned if any of the programs failed.
+func (o *execRun) wait() error {
+	<-o.settled
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var failedErr error
+
+	if len(o.failed) > 0 {
+		failedErr = fmt.Errorf("%d program(s) failed: %s",
+			len(o.failed), strings.Join(o.failed, ", "))
+	}
+
+	return errors.Join(o.err, failedErr)
+}
+
+// isSettled returns true if the run's programs have exited.
+func (o *execRun) isSettled() bool {
+	select {
+	case <-o.settled:
+		return true
+	default:
+		return false
+	}
+}
+
+// onSleep executes the sleep programs and waits for them to exit.
+// Blocking here delays sleep, which is why the programs are not
+// retried and are subject to o.sleepTimeout.
+func (o *execCtl) onSleep(ev event) *execRun {
+	run := newExecRun(ev)
+	defer o.settleRun(run)
+
+	exes, err := o.findExes(ev)
+	if err != nil {
+		errorf("failed to find executables - %s", err)
+
+		run.err = err
+	}
+
+	if o.dryRun {
+		logDryRun(ev, exes)
+
+		return run
+	}
+
+	for _, exe := range exes {
+		o.goExec(run, func() {
+			err := o.execOnce(o.ctx, ev, exe, nil, 1)
+			switch {
+			case errors.Is(err, skippedErr):
+				infof("[%s] not executing - %s", exe.path, err)
+			case err != nil:
+				errorf("[%s] sleep exec failed - %s", exe.path, err)
+			}
+
+			run.record(exe.path, err)
+		})
+	}
+
+	run.wg.Wait()
+
+	return run
+}
+
+// execRetry executes exe until it succeeds, gives up, or ctx is
+// done. If firstAttemptFn is non-nil, it is called after the
+// first execution attempt finishes.
+func (o *execCtl) execRetry(ctx context.Context, ev event, exe *exeInfo, firstAttemptFn func()) error {
+	exePath := exe.path
+	retries := 0
+	started := o.clock.Now()
+
+	c := o.addChild(exePath)
+	defer o.removeChild(c)
+
+	// executions is the number of times the program has been
+	// executed. Re-checking a condition that was not met does
+	// not execute it, so it does not count as an attempt.
+	executions := 0
+
+	for {
+		o.setChildAttempt(c, executions+1)
+
+		_, err := os.Stat(exePath)
+		if err != nil {
+			warnf("[%s] no longer stat'able - %s", exePath, err)
+
+			o.postWebhook(ev, exe, executions, started, err)
+
+			return err
+		}
+
+		err = o.acquireSlot(ctx, exe)
+		if err != nil {
+			infof("[%s] giving up while waiting to execute - %s",
+				exePath, context.Cause(ctx))
+
+			o.postWebhook(ev, exe, executions, started, err)
+
+			return err
+		}
+
+		err = o.execOnce(ctx, ev, exe, c, executions+1)
+
+		o.releaseSlot(exe)
+
+		if !isUnmetConditionErr(err) {
+			executions++
+		}
+
+		if firstAttemptFn != nil {
+			firstAttemptFn()
+			firstAttemptFn = nil
+		}
+
+		if err == nil {
+			o.postWebhook(ev, exe, executions, started, nil)
+
+			return nil
+		}
+
+		if errors.Is(err, stoppedErr) {
+			infof("[%s] not retrying - %s", exePath, err)
+
+			o.postWebhook(ev, exe, executions, started, err)
+
+			return err
+		}
+
+		if errors.Is(err, skippedErr) {
+			infof("[%s] not executing - %s", exePath, err)
+
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			infof("[%s] giving up - %s", exePath, context.Cause(ctx))
+
+			o.postWebhook(ev, exe, executions, started, ctx.Err())
+
+			return ctx.Err()
+		default:
+		}
+
+		// Rather than polling until the screen is unlocked,
+		// the program is executed again by the unlock event.
+		if errors.Is(err, screenLockedErr) {
+			debugf("[%s] screen is locked, will execute when it is unlocked",
+				exePath)
+
+			return fmt.Errorf("%w - %w", deferredErr, err)
+		}
+
+		// Waiting for a condition to be met is not a
+		// failure, so it does not count towards the
+		// backoff or the maximum number of retries.
+		waitFor := exe.retryInterval
+
+		if errors.Is(err, noNetworkErr) || errors.Is(err, unreachableErr) {
+			waitFor = min(waitFor, networkRetryInterval)
+		}
+
+		if isUnmetConditionErr(err) {
+			debugf("[%s] not executing, will re-check in %s - %s",
+				exePath, waitFor.String(), err)
+		} else {
+			if exe.maxRetries > 0 && retries >= exe.maxRetries {
+				errorf("[%s] exec failed, giving up after %d attempt(s) - %s",
+					exePath, retries+1, err)
+
+				o.notifyGaveUp(exePath, err)
+				o.postWebhook(ev, exe, executions, started, err)
+
+				return err
+			}
+
+			retries++
+
+			o.metrics.retries.Add(1)
+
+			waitFor = o.backoff.interval(exe.retryInterval, retries)
+
+			// Only the first failure is logged by default
+			// so that a program that keeps failing does not
+			// flood the log.
+			level := debugLevel
+			if retries == 1 {
+				level = warnLevel
+			}
+
+			logf(level, "[%s] exec failed, will retry in %s - %s",
+				exePath, waitFor.String(), err)
+		}
+
+		select {
+		case <-ctx.Done():
+			infof("[%s] giving up - %s", exePath, context.Cause(ctx))
+
+			o.postWebhook(ev, exe, executions, started, ctx.Err())
+
+			return ctx.Err()
+		case <-o.clock.After(waitFor):
+			continue
+		}
+	}
+}
+
+// acquireSlot blocks until fewer than o.concurrency programs are
+// executing and no other program in exe's group is executing, or
+// until ctx is done. The concurrency limit is a no-op if
+// o.concurrency is zero.
+func (o *execCtl) acquireSlot(ctx context.Context, exe *exeInfo) error {
+	err := o.acquireGroup(ctx, exe)
+	if err != nil {
+		return err
+	}
+
+	if o.slots == nil {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		o.releaseGroup(exe)
+
+		return ctx.Err()
+	case o.slots <- struct{}{}:
+		return nil
+	}
+}
+
+func (o *execCtl) releaseSlot(exe *exeInfo) {
+	o.releaseGroup(exe)
+
+	if o.slots == nil {
+		return
+	}
+
+	<-o.slots
+}
+
+// acquireGroup blocks until no other program in exe's group is
+// executing or ctx is done. It is a no-op if exe is not in a group.
+// The group is acquired before the concurrency limit so that a
+// program waiting for its group does not prevent other programs
+// from executing.
+func (o *execCtl) acquireGroup(ctx context.Context, exe *exeInfo) error {
+	if exe.group == "" {
+		return nil
+	}
+
+	o.groupsMu.Lock()
+
+	if o.groupSlots == nil {
+		o.groupSlots = make(map[string]chan struct{})
+	}
+
+	slot, ok := o.groupSlots[exe.group]
+	if !ok {
+		slot = make(chan struct{}, 1)
+		o.groupSlots[exe.group] = slot
+	}
+
+	o.groupsMu.Unlock()
+
+	select {
+	case slot <- struct{}{}:
+		return nil
+	default:
+	}
+
+	debugf("[%s] waiting for the other program in group %q to exit", exe.path, exe.group)
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case slot <- struct{}{}:
+		return nil
+	}
+}
+
+func (o *execCtl) releaseGroup(exe *exeInfo) {
+	if exe.group == "" {
+		return
+	}
+
+	o.groupsMu.Lock()
+	slot := o.groupSlots[exe.group]
+	o.groupsMu.Unlock()
+
+	<-slot
+}
+
+var (
+	screenLockedErr = errors.New("screen is locked")
+	onBatteryErr    = errors.New("computer is running on battery")
+
+	// skippedErr means that a program was deliberately not
+	// executed for an event. It is not retried.
+	skippedErr = errors.New("skipped")
+
+	// deferredErr means that a program will be executed by a
+	// later event instead (e.g., once the screen is unlocked).
+	deferredErr = fmt.Errorf("%w - deferred", skippedErr)
+)
+
+// isUnmetConditionErr returns true if err means that a program
+// was not executed because a condition it requires is not met.
+func isUnmetConditionErr(err error) bool {
+	return errors.Is(err, onBatteryErr) ||
+		errors.Is(err, noNetworkErr) ||
+		errors.Is(err, unreachableErr)
+}
+
+// execOnce executes the exeInfo once. attempt is the number of
+// times the program has been executed for ev, including this time,
+// and is passed to the program. If c is non-nil, the program's
+// process is recorded in c while it runs.
+func (o *execCtl) execOnce(ctx context.Context, ev event, exeInfo *exeInfo, c *child, attempt int) error {
+	exePath := exeInfo.path
+
+	if exeInfo.window != nil && !exeInfo.window.contains(o.clock.Now()) {
+		return fmt.Errorf("%w - current time is outside of %s",
+			skippedErr, exeInfo.window)
+	}
+
+	if exeInfo.runOnce {
+		ranAt, ran := o.ranOnce(exePath)
+		if ran {
+			return fmt.Errorf("%w - already succeeded once at %s",
+				skippedErr, ranAt.Format(time.DateTime))
+		}
+	}
+
+	if exeInfo.minInterval > 0 {
+		succeeded, hasSucceeded := o.lastSucceeded(exePath)
+		sinceSucceeded := o.clock.Now().Sub(succeeded)
+
+		if hasSucceeded && sinceSucceeded < exeInfo.minInterval {
+			return fmt.Errorf("%w - last succeeded %s ago, which is less than %s",
+				skippedErr, sinceSucceeded.Round(time.Second), exeInfo.minInterval)
+		}
+	}
+
+	if exeInfo.needsUnlock {
+		isLocked, err := o.isScreenLocked(ctx)
+		switch {
+		case isLocked:
+			return screenLockedErr
+		case err != nil:
+			warnf("failed to determine if screen is locked - %s", err)
+		}
+	}
+
+	if exeInfo.needsAC {
+		// Assume the computer is connected to AC power if
+		// it does not know better (e.g., it has no battery).
+		isOnAC, ok := isOnACPower()
+		if ok && !isOnAC {
+			return onBatteryErr
+		}
+	}
+
+	if exeInfo.needsNetwork {
+		err := checkNetwork(o.networkHost)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := checkReachable(exeInfo.waitFor)
+	if err != nil {
+		return err
+	}
+
+	stopCtx := ctx
+
+	if exeInfo.warnOnTimeout {
+		exited := make(chan struct{})
+		defer close(exited)
+
+		go func() {
+			select {
+			case <-exited:
+			case <-o.clock.After(exeInfo.timeout):
+				warnf("[%s] still running after %s, not stopping it because -%s is %s",
+					exePath, exeInfo.timeout, timeoutActArg, warnTimeoutAction)
+			}
+		}()
+	} else {
+		var cancelFn context.CancelFunc
+
+		ctx, cancelFn = context.WithTimeoutCause(
+			ctx,
+			exeInfo.timeout,
+			fmt.Errorf("timed-out after %s", exeInfo.timeout))
+		defer cancelFn()
+	}
+
+	name, args := exeInfo.command()
+
+	if o.loginShellPath != "" {
+		// The command is passed as positional parameters
+		// rather than as part of the script so that it
+		// does not need to be quoted.
+		args = append([]string{"-l", "-c", `exec "$0" "$@"`, name}, args...)
+		name = o.loginShellPath
+	}
+
+	exe := exec.CommandContext(ctx, name, args...)
+
+	// launchd executes waked in '/', which means relative
+	// paths would otherwise not refer to the program's
+	// sibling files.
+	exe.Dir = o.workdir
+	if exe.Dir == "" {
+		exe.Dir = filepath.Dir(exePath)
+	}
+
+	exe.Env = os.Environ()
+	if o.cleanEnv {
+		exe.Env = minimalEnv()
+	}
+
+	exe.Env = append(exe.Env, ev.env()...)
+	exe.Env = append(exe.Env, "WAKED_ATTEMPT="+strconv.Itoa(attempt))
+
+	if o.runAsCred != nil {
+		exe.SysProcAttr = &syscall.SysProcAttr{
+			Credential: o.runAsCred,
+		}
+
+		exe.Env = append(exe.Env, o.runAsEnv...)
+	}
+
+	// Later values take precedence, which allows -env
+	// to override any of the above.
+	exe.Env = append(exe.Env, o.envVars...)
+
+	// Give the program a chance to clean up when it is stopped.
+	// It is killed if it does not exit within o.termGrace.
+	exe.Cancel = func() error {
+		return exe.Process.Signal(exeInfo.killSignal)
+	}
+	exe.WaitDelay = o.termGrace
+
+	// The limit is shared by both streams.
+	limit := &outputLimit{max: o.maxOutput}
+
+	stderr := newExeLogger(exePath, "stderr", o.logDir, limit, o.collapseRepeats)
+	defer stderr.Close()
+
+	stdout := newExeLogger(exePath, "stdout", o.logDir, limit, o.collapseRepeats)
+	defer stdout.Close()
+
+	exe.Stderr = stderr
+	exe.Stdout = stdout
+
+	if o.stdinEvent {
+		payload, err := o.eventPayload(ctx, ev)
+		if err != nil {
+			return fmt.Errorf("failed to encode event payload - %w", err)
+		}
+
+		// exec closes the program's stdin once the
+		// payload is written.
+		exe.Stdin = bytes.NewReader(payload)
+	}
+
+	started := o.clock.Now()
+
+	err = exe.Start()
+	if err != nil {
+		err = fmt.Errorf("exec failed - %w", err)
+
+		o.recordRun(exeInfo, started, err)
+
+		return err
+	}
+
+	o.metrics.executions.Add(1)
+
+	o.setChildCmd(c, exe)
+	defer o.setChildCmd(c, nil)
+
+	err = exe.Wait()
+
+	// WaitDelay also expires when the program exits, but a
+	// process it started in the background still has its
+	// output open. The program itself did not fail.
+	if errors.Is(err, exec.ErrWaitDelay) && exe.ProcessState.Success() {
+		warnf("[%s] exited, but its output was still open after %s (e.g., because of a background process) - any further output is discarded",
+			exePath, o.termGrace)
+
+		err = nil
+	}
+
+	// Any remaining output (e.g., the number of times the
+	// last line was repeated) is logged before the result.
+	stderr.Close()
+	stdout.Close()
+
+	err = o.exitErr(ctx, stopCtx, exePath, o.clock.Now().Sub(started), err)
+
+	o.recordRun(exeInfo, started, err)
+
+	return err
+}
+
+// exitErr returns the error that execOnce returns for a program
+// that exited after running for elapsed. err is the error returned
+// by exec.Cmd.Wait, ctx is the program's context, and stopCtx is
+// its parent context.
+func (o *execCtl) exitErr(ctx context.Context, stopCtx context.Context, exePath string, elapsed time.Duration, err error) error {
+	err = o.classifyExit(ctx, stopCtx, exePath, elapsed, err)
+
+	// The execution is recorded once its exit is classified
+	// because exit statuses in o.noRetryCodes are not failures.
+	o.metrics.addExecution(err, elapsed)
+
+	return err
+}
+
+// classifyExit logs how the program exited and returns the
+// error that exitErr returns. Its arguments are the same as
+// exitErr's.
+func (o *execCtl) classifyExit(ctx context.Context, stopCtx context.Context, exePath string, elapsed time.Duration, err error) error {
+	elapsed = elapsed.Round(time.Millisecond)
+
+	if err == nil {
+		infof("[%s] succeeded after %s", exePath, elapsed)
+
+		return nil
+	}
+
+	code, hasCode := exitCode(err)
+	if _, noRetry := o.noRetryCodes[code]; hasCode && noRetry {
+		infof("[%s] exited with status %d, which means it has nothing to do",
+			exePath, code)
+
+		return nil
+	}
+
+	// A program killed because it was stopped did not
+	// fail, so there is no point in retrying it.
+	if sig, wasSignaled := exitSignal(err); wasSignaled && stopCtx.Err() != nil {
+		return fmt.Errorf("%w by %s - %w", stoppedErr, sig, context.Cause(stopCtx))
+	}
+
+	// Explain why the program was killed (e.g., because
+	// it timed-out) rather than just how.
+	if ctx.Err() != nil {
+		return fmt.Errorf("exec failed - %w - %w", context.Cause(ctx), err)
+	}
+
+	return fmt.Errorf("exec failed after %s - %w", elapsed, err)
+}
+
+// stoppedErr is returned by execOnce when the program is killed
+// because its parent context is done (e.g., because a new wake
+// event occurred).
+var stoppedErr = errors.New("program was stopped")
+
+// exitSignal returns the signal that killed the program that
+// produced err. It returns false if the program was not killed
+// by a signal.
+func exitSignal(err error) (syscall.Signal, bool) {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return 0, false
+	}
+
+	waitStatus, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok || !waitStatus.Signaled() {
+		return 0, false
+	}
+
+	return waitStatus.Signal(), true
+}
+
+// exitCode returns the exit status of the program that
+// produced err. It returns false if the program did not
+// exit normally (e.g., because it was killed by a signal).
+func exitCode(err error) (int, bool) {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return 0, false
+	}
+
+	code := exitErr.ExitCode()
+
+	return code, code >= 0
+}
+
+// defaultPath is the PATH used by minimalEnv if waked's
+// environment does not specify one.
+const defaultPath = "/usr/bin:/bin:/usr/sbin:/sbin"
+
+// minimalEnv returns the environment used by -clean-env, which
+// only contains PATH, HOME, and USER.
+func minimalEnv() []string {
+	path := os.Getenv("PATH")
+	if path == "" {
+		path = defaultPath
+	}
+
+	env := []string{"PATH=" + path}
+
+	home, err := os.UserHomeDir()
+	if err == nil {
+		env = append(env, "HOME="+home)
+	}
+
+	// launchd does not always set USER.
+	username := os.Getenv("USER")
+	if username == "" {
+		u, err := user.Current()
+		if err == nil {
+			username = u.Username
+		}
+	}
+
+	if username != "" {
+		env = append(env, "USER="+username)
+	}
+
+	return env
+}
+
+// newExeLogger returns an io.WriteCloser that logs each line
+// written to it. stream is the name of the program's output
+// stream (e.g., "stdout") and is included in each log message.
+// If logDir is non-empty, the lines are also appended to the
+// program's log file in logDir. If limit is non-nil, lines are
+// no longer logged once it is exceeded. If collapse is true,
+// consecutive identical lines are logged once, followed by the
+// number of times the line was repeated.
+func newExeLogger(exePath string, stream string, logDir string, limit *outputLimit, collapse bool) *exeLogger {
+	r, w := io.Pipe()
+
+	l := &exeLogger{
+		exePath:  exePath,
+		stream:   stream,
+		r:        r,
+		w:        w,
+		limit:    limit,
+		collapse: collapse,
+		done:     make(chan struct{}),
+	}
+
+	if logDir != "" {
+		logFilePath := filepath.Join(logDir, filepath.Base(exePath)+".log")
+
+		f, err := os.OpenFile(logFilePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			warnf("[%s] failed to open log file - %s", exePath, err)
+		} else {
+			l.file = f
+			l.fileLogger = log.New(f, "", log.LstdFlags)
+		}
+	}
+
+	go l.loop()
+
+	return l
+}
+
+type exeLogger struct {
+	exePath    string
+	stream     string
+	r          io.ReadCloser
+	w          io.WriteCloser
+	file       *os.File
+	fileLogger *log.Logger
+	limit      *outputLimit
+	collapse   bool
+	done       chan struct{}
+
+	// lastLine is the last line that was logged and repeats
+	// is the number of times it was repeated since. hasLastLine
+	// is false until the first line is logged. They are only
+	// used if collapse is true.
+	lastLine    string
+	hasLastLine bool
+	repeats     int
+}
+
+func (o *exeLogger) Write(b []byte) (int, error) {
+	return o.w.Write(b)
+}
+
+// Close waits for the output that was already written to be
+// logged, including a final line that does not end with a
+// newline. exec.Cmd.Wait only returns once the program's output
+// has been copied to the exeLogger (or once its WaitDelay expires),
+// which means the output written before the program was killed
+// is not lost. Close may be called more than once.
+func (o *exeLogger) Close() error {
+	o.w.Close()
+	<-o.done
+
+	if o.file != nil {
+		o.file.Close()
+	}
+
+	return nil
+}
+
+func (o *exeLogger) loop() {
+	defer close(o.done)
+
+	// Whatever is left is discarded so that the program
+	// does not block writing output that nobody reads.
+	defer io.Copy(io.Discard, o.r)
+
+	scanner := bufio.NewScanner(o.r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxLogLineSize)
+	scanner.Split(scanLinesOrChunks)
+
+	// A line that was repeated when the program exits
+	// is otherwise never reported.
+	defer o.logRepeats()
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if o.collapse {
+			if o.hasLastLine && line == o.lastLine {
+				o.repeats++
+
+				continue
+			}
+
+			o.logRepeats()
+			o.lastLine = line
+			o.hasLastLine = true
+		}
+
+		if !o.limit.allow(len(scanner.Bytes()) + 1) {
+			if o.limit.exceed() {
+				warnf("[%s %s] output exceeded %d bytes, not logging further output",
+					o.exePath, o.stream, o.limit.max)
+			}
+
+			continue
+		}
+
+		o.log(line)
+	}
+
+	// The pipe's writer is closed by Close once the program
+	// exits, which the scanner treats as the end of the output.
+	err := scanner.Err()
+	if err != nil {
+		warnf("[%s %s] failed to read output, not logging further output - %s",
+			o.exePath, o.stream, err)
+	}
+}
+
+// logRepeats logs the number of times the last line was
+// repeated, if it was repeated at all.
+func (o *exeLogger) logRepeats() {
+	if o.repeats == 0 || (o.limit != nil && o.limit.exceeded.Load()) {
+		return
+	}
+
+	o.log(fmt.Sprintf("previous line repeated %d times", o.repeats))
+
+	o.repeats = 0
+}
+
+// log logs a line of the program's output.
+func (o *exeLogger) log(line string) {
+	infof("[%s %s] %s", o.exePath, o.stream, line)
+
+	if o.fileLogger != nil {
+		o.fileLogger.Printf("[%s] %s", o.stream, line)
+	}
+}
+
+// maxLogLineSize is the maximum size of a line of a program's
+// output. Longer lines are logged in chunks of this size.
+const maxLogLineSize = 1024 * 1024
+
+// scanLinesOrChunks is a bufio.SplitFunc that is equivalent to
+// bufio.ScanLines, except that lines longer than maxLogLineSize
+// are split into multiple tokens rather than causing the scanner
+// to fail with bufio.ErrTooLong.
+func scanLinesOrChunks(data []byte, atEOF bool) (int, []byte, error) {
+	advance, token, err := bufio.ScanLines(data, atEOF)
+	if advance == 0 && token == nil && err == nil && len(data) >= maxLogLineSize {
+		return maxLogLineSize, data[:maxLogLineSize], nil
+	}
+
+	return advance, token, err
+}
+
+// outputLimit limits the number of bytes of a program's output
+// that are logged. A nil outputLimit or a max of zero allows all
+// output to be logged.
+type outputLimit struct {
+	max      int64
+	used     atomic.Int64
+	exceeded atomic.Bool
+}
+
+// allow records that n bytes are about to be logged. It returns
+// false if logging them would exceed the limit.
+func (o *outputLimit) allow(n int) bool {
+	if o == nil || o.max <= 0 {
+		return true
+	}
+
+	return o.used.Add(int64(n)) <= o.max
+}
+
+// exceed marks the limit as exceeded. It returns true the
+// first time it is called.
+func (o *outputLimit) exceed() bool {
+	return o.exceeded.CompareAndSwap(false, true)
+}
+
+// lockState is the cached result of checkIfLocked.
+type lockState struct {
+	locked  bool
+	err     error
+	checked time.Time
+}
+
+// isScreenLocked returns the result of checkIfLocked. The result
+// is reused for lockStateTTL so that programs checking at the
+// same time do not each run checkIfLocked. Concurrent callers
+// wait for the first caller's check to finish.
+func (o *execCtl) isScreenLocked(ctx context.Context) (bool, error) {
+	o.lockStateMu.Lock()
+	defer o.lockStateMu.Unlock()
+
+	if !o.lockState.checked.IsZero() && time.Since(o.lockState.checked) < lockStateTTL {
+		return o.lockState.locked, o.lockState.err
+	}
+
+	checkCtx, cancelFn := context.WithTimeoutCause(
+		ctx,
+		lockCheckTimeout,
+		fmt.Errorf("lock check timed out after %s", lockCheckTimeout))
+	defer cancelFn()
+
+	locked, err := checkIfLocked(checkCtx)
+
+	// Errors caused by ctx are specific to the caller,
+	// so they are not cached.
+	if ctx.Err() != nil {
+		return locked, err
+	}
+
+	// The lock state is unknown, which means programs are
+	// executed (with a warning) rather than skipped.
+	if checkCtx.Err() != nil {
+		locked, err = false, context.Cause(checkCtx)
+	}
+
+	o.lockState = lockState{
+		locked:  locked,
+		err:     err,
+		checked: time.Now(),
+	}
 
-	return bytes.Equal([]byte("true"), plutilOutput), nil
+	return locked, err
 }
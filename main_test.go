@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestExecCtl returns a validated execCtl whose executables
+// directory is a temporary directory.
+func newTestExecCtl(t *testing.T, c clock) *execCtl {
+	t.Helper()
+
+	ctl := &execCtl{
+		ctx:           context.Background(),
+		exesDirs:      []string{t.TempDir()},
+		sleepTimeout:  time.Minute,
+		termGrace:     time.Second,
+		killSignalArg: "SIGTERM",
+		timeoutAction: killTimeoutAction,
+		clock:         c,
+		backoff: backoff{
+			kind: fixedBackoff,
+		},
+		defaults: exeInfo{
+			timeout:       time.Minute,
+			retryInterval: defaultRetryInterval,
+		},
+	}
+
+	err := ctl.validate()
+	if err != nil {
+		t.Fatalf("failed to validate execCtl - %s", err)
+	}
+
+	return ctl
+}
+
+// writeTestExe writes a shell script containing script to the
+// execCtl's executables directory and returns its exeInfo.
+func writeTestExe(t *testing.T, ctl *execCtl, name string, script string) *exeInfo {
+	t.Helper()
+
+	exePath := filepath.Join(ctl.exesDirs[0], name)
+
+	err := os.WriteFile(exePath, []byte("#!/bin/sh\n"+script+"\n"), 0o700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exe := ctl.defaults
+	exe.path = exePath
+
+	return &exe
+}
+
+// captureLog redirects the log package's output to a buffer
+// until the test finishes.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	buf := bytes.NewBuffer(nil)
+
+	prev := log.Writer()
+	log.SetOutput(buf)
+	t.Cleanup(func() {
+		log.SetOutput(prev)
+	})
+
+	return buf
+}
+
+func TestExecRetryBackoff(t *testing.T) {
+	tests := []struct {
+		name       string
+		script     string
+		backoff    backoff
+		maxRetries int
+		wantErr    bool
+		wantWaits  []time.Duration
+	}{
+		{
+			name:       "gives up after max retries",
+			script:     "exit 1",
+			backoff:    backoff{kind: exponentialBackoff},
+			maxRetries: 3,
+			wantErr:    true,
+			wantWaits:  []time.Duration{time.Second, 2 * time.Second, 4 * time.Second},
+		},
+		{
+			name:       "exponential capped by max",
+			script:     "exit 1",
+			backoff:    backoff{kind: exponentialBackoff, max: 3 * time.Second},
+			maxRetries: 4,
+			wantErr:    true,
+			wantWaits:  []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second},
+		},
+		{
+			name:       "no backoff",
+			script:     "exit 1",
+			backoff:    backoff{kind: noBackoff},
+			maxRetries: 2,
+			wantErr:    true,
+			wantWaits:  []time.Duration{0, 0},
+		},
+		{
+			name:       "succeeds before giving up",
+			script:     `[ "$WAKED_ATTEMPT" -ge 3 ]`,
+			backoff:    backoff{kind: fixedBackoff},
+			maxRetries: 5,
+			wantWaits:  []time.Duration{time.Second, time.Second},
+		},
+		{
+			name:       "succeeds on first attempt",
+			script:     "exit 0",
+			backoff:    backoff{kind: fixedBackoff},
+			maxRetries: 5,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newFakeClock()
+
+			ctl := newTestExecCtl(t, c)
+			ctl.backoff = test.backoff
+
+			exe := writeTestExe(t, ctl, "retry.sh", test.script)
+			exe.maxRetries = test.maxRetries
+			exe.retryInterval = time.Second
+
+			captureLog(t)
+
+			err := ctl.execRetry(context.Background(), event{name: wakeNotification}, exe, nil)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v - want error: %t", err, test.wantErr)
+			}
+
+			waits := c.Waits()
+			if !slices.Equal(waits, test.wantWaits) {
+				t.Fatalf("got waits %v - want %v", waits, test.wantWaits)
+			}
+
+			wantAttempts := uint64(len(test.wantWaits) + 1)
+			if attempts := ctl.metrics.executions.Load(); attempts != wantAttempts {
+				t.Fatalf("got %d attempt(s) - want %d", attempts, wantAttempts)
+			}
+		})
+	}
+}
+
+func TestExecRetryGivingUpLogsAttemptsAndError(t *testing.T) {
+	ctl := newTestExecCtl(t, newFakeClock())
+
+	exe := writeTestExe(t, ctl, "fail.sh", "exit 3")
+	exe.maxRetries = 2
+
+	logs := captureLog(t)
+
+	err := ctl.execRetry(context.Background(), event{name: wakeNotification}, exe, nil)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	var gaveUp string
+	for _, line := range strings.Split(logs.String(), "\n") {
+		if strings.Contains(line, "giving up") {
+			gaveUp = line
+		}
+	}
+
+	if gaveUp == "" {
+		t.Fatalf("giving up message was not logged - log:\n%s", logs)
+	}
+
+	for _, want := range []string{"after 3 attempt(s)", "exit status 3"} {
+		if !strings.Contains(gaveUp, want) {
+			t.Fatalf("giving up message %q does not contain %q", gaveUp, want)
+		}
+	}
+}
+
+func TestExecRetryCancelledWhileWaitingIsStopped(t *testing.T) {
+	ctl := newTestExecCtl(t, realClock{})
+
+	exe := writeTestExe(t, ctl, "fail.sh", "exit 1")
+	exe.retryInterval = time.Hour
+
+	captureLog(t)
+
+	// This is how -max-uptime stops the programs.
+	ctx, cancelFn := context.WithCancelCause(context.Background())
+	defer cancelFn(nil)
+
+	timer := time.AfterFunc(100*time.Millisecond, func() {
+		cancelFn(errors.New("reached maximum uptime"))
+	})
+	defer timer.Stop()
+
+	err := ctl.execRetry(ctx, event{name: wakeNotification}, exe, nil)
+
+	result := newExeResult(exe.path, err)
+	if result.Status != stoppedStatus {
+		t.Fatalf("got status %q - want %q (error: %v)", result.Status, stoppedStatus, err)
+	}
+}
+
+// listenOnWaitClock is a fakeClock that starts listening on addr
+// the first time After is called.
+type listenOnWaitClock struct {
+	*fakeClock
+	t    *testing.T
+	addr string
+	once sync.Once
+}
+
+func (o *listenOnWaitClock) After(d time.Duration) <-chan time.Time {
+	o.once.Do(func() {
+		listener, err := net.Listen("tcp", o.addr)
+		if err != nil {
+			o.t.Errorf("failed to listen on %s - %s", o.addr, err)
+			return
+		}
+
+		o.t.Cleanup(func() {
+			listener.Close()
+		})
+	})
+
+	return o.fakeClock.After(d)
+}
+
+func TestExecRetryUnmetConditionIsNotAnAttempt(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := listener.Addr().String()
+	listener.Close()
+
+	c := &listenOnWaitClock{fakeClock: newFakeClock(), t: t, addr: addr}
+
+	ctl := newTestExecCtl(t, c)
+
+	attemptsPath := filepath.Join(t.TempDir(), "attempts")
+
+	exe := writeTestExe(t, ctl, "wait.sh",
+		`echo "$WAKED_ATTEMPT" >> "`+attemptsPath+`"; [ "$WAKED_ATTEMPT" -ge 2 ]`)
+	exe.retryInterval = time.Second
+	exe.waitFor = []string{addr}
+
+	captureLog(t)
+
+	err = ctl.execRetry(context.Background(), event{name: wakeNotification}, exe, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attempts, err := os.ReadFile(attemptsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(attempts) != "1\n2\n" {
+		t.Fatalf("got WAKED_ATTEMPT values %q - want %q", attempts, "1\n2\n")
+	}
+
+	if executions := ctl.metrics.executions.Load(); executions != 2 {
+		t.Fatalf("got %d execution(s) - want 2", executions)
+	}
+}
+
+func TestExeLoggerCollapse(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		collapse bool
+		want     []string
+	}{
+		{
+			name:     "leading empty line",
+			output:   "\nhello\n",
+			collapse: true,
+			want:     []string{"", "hello"},
+		},
+		{
+			name:     "repeated empty lines",
+			output:   "\n\n\nhello\n",
+			collapse: true,
+			want:     []string{"", "previous line repeated 2 times", "hello"},
+		},
+		{
+			name:     "repeated last line",
+			output:   "a\nb\nb\nb",
+			collapse: true,
+			want:     []string{"a", "b", "previous line repeated 2 times"},
+		},
+		{
+			name:   "not collapsed",
+			output: "a\na\n",
+			want:   []string{"a", "a"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logs := captureLog(t)
+			prevFlags := log.Flags()
+			log.SetFlags(0)
+			t.Cleanup(func() {
+				log.SetFlags(prevFlags)
+			})
+
+			l := newExeLogger("/exes/a.sh", "stdout", "", nil, test.collapse)
+
+			_, err := l.Write([]byte(test.output))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			l.Close()
+
+			var got []string
+
+			for _, line := range strings.Split(strings.TrimSuffix(logs.String(), "\n"), "\n") {
+				got = append(got, strings.TrimPrefix(line, "[/exes/a.sh stdout] "))
+			}
+
+			if !slices.Equal(got, test.want) {
+				t.Fatalf("got %q - want %q", got, test.want)
+			}
+		})
+	}
+}
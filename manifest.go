@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// manifestEntry is a line in a manifest file.
+type manifestEntry struct {
+	exePath string
+	args    []string
+}
+
+// readManifest parses the manifest file at filePath. Each line
+// contains the path to an executable optionally followed by
+// arguments separated by whitespace. Blank lines and lines
+// starting with '#' are ignored. Relative executable paths are
+// relative to the manifest's directory.
+func readManifest(filePath string) ([]manifestEntry, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []manifestEntry
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		exePath := fields[0]
+		if !filepath.IsAbs(exePath) {
+			exePath = filepath.Join(filepath.Dir(filePath), exePath)
+		}
+
+		entries = append(entries, manifestEntry{
+			exePath: filepath.Clean(exePath),
+			args:    fields[1:],
+		})
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// findExesInManifest returns the executables listed in o.manifest
+// that should be executed for the specified event. The manifest is
+// re-read each time it is called.
+func (o *execCtl) findExesInManifest(ev event) ([]*exeInfo, error) {
+	entries, err := readManifest(o.manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest %q - %w", o.manifest, err)
+	}
+
+	var exes []*exeInfo
+
+	for _, entry := range entries {
+		_, err := os.Stat(entry.exePath)
+		if err != nil {
+			warnf("[%s] skipping executable listed in manifest - %s",
+				entry.exePath, err)
+
+			continue
+		}
+
+		exe, ok := o.exeForEvent(ev, entry.exePath, nil)
+		if !ok {
+			continue
+		}
+
+		exe.args = append(slices.Clone(exe.args), entry.args...)
+
+		exes = append(exes, exe)
+	}
+
+	return exes, nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// metrics are counters that are exposed in the Prometheus
+// text format by execCtl.serveMetrics.
+type metrics struct {
+	eventsMu sync.Mutex
+	events   map[string]uint64
+
+	executions atomic.Uint64
+	successes  atomic.Uint64
+	failures   atomic.Uint64
+	retries    atomic.Uint64
+
+	// executionNanos is the total amount of time that
+	// programs ran for.
+	executionNanos atomic.Int64
+}
+
+func (o *metrics) addEvent(name string) {
+	o.eventsMu.Lock()
+	defer o.eventsMu.Unlock()
+
+	if o.events == nil {
+		o.events = make(map[string]uint64)
+	}
+
+	o.events[name]++
+}
+
+// addExecution records the result of an execution that ran for
+// elapsed. err is the error returned by exec.Cmd.Wait.
+func (o *metrics) addExecution(err error, elapsed time.Duration) {
+	o.executionNanos.Add(int64(elapsed))
+
+	if err == nil {
+		o.successes.Add(1)
+	} else {
+		o.failures.Add(1)
+	}
+}
+
+// serveMetrics serves metrics over HTTP at addr (e.g., ":9100")
+// in the Prometheus text format. The server stops when the
+// returned listener is closed.
+func (o *execCtl) serveMetrics(addr string) (net.Listener, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for metrics clients - %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+		o.writeMetrics(w)
+	})
+
+	server := &http.Server{
+		Handler:      mux,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, net.ErrClosed) {
+			warnf("failed to serve metrics - %s", err)
+		}
+	}()
+
+	return listener, nil
+}
+
+func (o *execCtl) writeMetrics(w io.Writer) {
+	writeMetric := func(name string, kind string, help string, value uint64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n",
+			name, help, name, kind, name, value)
+	}
+
+	o.metrics.eventsMu.Lock()
+
+	var eventNames []string
+	for name := range o.metrics.events {
+		eventNames = append(eventNames, name)
+	}
+
+	sort.Strings(eventNames)
+
+	fmt.Fprintf(w, "# HELP %s_events_total The number of events received.\n"+
+		"# TYPE %s_events_total counter\n", appName, appName)
+
+	for _, name := range eventNames {
+		fmt.Fprintf(w, "%s_events_total{event=%q} %d\n",
+			appName, name, o.metrics.events[name])
+	}
+
+	o.metrics.eventsMu.Unlock()
+
+	writeMetric(appName+"_executions_total", "counter",
+		"The number of times programs were executed.",
+		o.metrics.executions.Load())
+
+	writeMetric(appName+"_successes_total", "counter",
+		"The number of executions that succeeded.",
+		o.metrics.successes.Load())
+
+	writeMetric(appName+"_failures_total", "counter",
+		"The number of executions that failed.",
+		o.metrics.failures.Load())
+
+	fmt.Fprintf(w, "# HELP %s_execution_seconds_total The total amount of time programs ran for.\n"+
+		"# TYPE %s_execution_seconds_total counter\n%s_execution_seconds_total %g\n",
+		appName, appName, appName,
+		time.Duration(o.metrics.executionNanos.Load()).Seconds())
+
+	writeMetric(appName+"_retries_total", "counter",
+		"The number of times failed programs were scheduled to be retried.",
+		o.metrics.retries.Load())
+
+	o.childrenMu.Lock()
+
+	running := 0
+	for _, c := range o.children {
+		if c.cmd != nil {
+			running++
+		}
+	}
+
+	o.childrenMu.Unlock()
+
+	writeMetric(appName+"_running_programs", "gauge",
+		"The number of programs that are currently running.",
+		uint64(running))
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	// networkRetryInterval is the amount of time to wait before
+	// re-checking the network for a program that requires it.
+	// It is short because the network usually becomes available
+	// shortly after waking.
+	networkRetryInterval = 2 * time.Second
+
+	networkDialTimeout = 2 * time.Second
+)
+
+// defaultRouteAddrs are used to check if the computer has a
+// default route. Connecting a UDP socket does not send any
+// packets, but it fails if there is no route to the address.
+var defaultRouteAddrs = []string{
+	"1.1.1.1:53",
+	"[2606:4700:4700::1111]:53",
+}
+
+var (
+	noNetworkErr   = errors.New("network is not available")
+	unreachableErr = errors.New("host is not reachable")
+)
+
+// checkNetwork returns noNetworkErr if the network is not
+// available. If host is non-empty, the network is available
+// if a TCP connection to host (in the format "host:port")
+// succeeds. Otherwise, it is available if the computer has
+// a default route.
+func checkNetwork(host string) error {
+	if host != "" {
+		conn, err := net.DialTimeout("tcp", host, networkDialTimeout)
+		if err != nil {
+			return fmt.Errorf("%w - %w", noNetworkErr, err)
+		}
+
+		conn.Close()
+
+		return nil
+	}
+
+	var errs []error
+
+	for _, addr := range defaultRouteAddrs {
+		conn, err := net.Dial("udp", addr)
+		if err == nil {
+			conn.Close()
+
+			return nil
+		}
+
+		errs = append(errs, err)
+	}
+
+	return fmt.Errorf("%w - %w", noNetworkErr, errors.Join(errs...))
+}
+
+// checkReachable returns unreachableErr if a TCP connection
+// to any of the addresses (in the format "host:port") fails.
+func checkReachable(addrs []string) error {
+	for _, addr := range addrs {
+		conn, err := net.DialTimeout("tcp", addr, networkDialTimeout)
+		if err != nil {
+			return fmt.Errorf("%w: %s - %w", unreachableErr, addr, err)
+		}
+
+		conn.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"path/filepath"
+	"time"
+)
+
+const notifyTimeout = 10 * time.Second
+
+// notifyGaveUp posts a user notification saying that the program
+// failed and will not be retried. It is a no-op unless o.notify
+// is true. It does not block.
+func (o *execCtl) notifyGaveUp(exePath string, err error) {
+	if !o.notify {
+		return
+	}
+
+	go func() {
+		err := postNotification(
+			appName+": "+filepath.Base(exePath)+" failed",
+			err.Error())
+		if err != nil {
+			warnf("[%s] failed to post user notification - %s", exePath, err)
+		}
+	}()
+}
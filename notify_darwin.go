@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+)
+
+// postNotification displays a user notification.
+//
+// NSUserNotificationCenter and UNUserNotificationCenter only work
+// for programs that have a bundle identifier, which command line
+// programs do not. osascript's "display notification" command
+// works regardless. The title and message are passed as arguments
+// rather than being formatted into the script, which avoids
+// needing to escape them.
+func postNotification(title string, message string) error {
+	ctx, cancelFn := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancelFn()
+
+	osascript := exec.CommandContext(
+		ctx,
+		"/usr/bin/osascript",
+		"-e", "on run argv",
+		"-e", "display notification (item 2 of argv) with title (item 1 of argv)",
+		"-e", "end run",
+		title,
+		message)
+
+	output, err := osascript.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("osascript failed - %w - output: %q", err, output)
+	}
+
+	return nil
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+)
+
+// postNotification displays a user notification using
+// notify-send, which is provided by libnotify.
+func postNotification(title string, message string) error {
+	ctx, cancelFn := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancelFn()
+
+	notifySend := exec.CommandContext(ctx, "notify-send", "--", title, message)
+
+	output, err := notifySend.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("notify-send failed - %w - output: %q", err, output)
+	}
+
+	return nil
+}
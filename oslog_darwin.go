@@ -0,0 +1,61 @@
+package main
+
+/*
+#include <os/log.h>
+#include <stdlib.h>
+
+static os_log_t appLog;
+
+static void osLogInit(const char *subsystem) {
+	appLog = os_log_create(subsystem, "default");
+}
+
+static void osLogWrite(os_log_type_t logType, const char *msg) {
+	os_log_with_type(appLog, logType, "%{public}s", msg);
+}
+*/
+import "C"
+
+import (
+	"strings"
+	"unsafe"
+)
+
+// osLog writes messages to Apple's unified logging system, which
+// can be viewed using Console.app or "log stream". Go's log/syslog
+// package does not work on macOS:
+// https://github.com/golang/go/issues/59229
+type osLog struct{}
+
+func newOSLog(subsystem string) (osLog, error) {
+	cSubsystem := C.CString(subsystem)
+	defer C.free(unsafe.Pointer(cSubsystem))
+
+	C.osLogInit(cSubsystem)
+
+	return osLog{}, nil
+}
+
+// Write writes b as a default-level message. It allows osLog
+// to be used as the standard logger's output.
+func (o osLog) Write(b []byte) (int, error) {
+	o.log(infoLevel, strings.TrimSuffix(string(b), "\n"))
+
+	return len(b), nil
+}
+
+func (o osLog) log(level logLevel, msg string) {
+	logType := C.os_log_type_t(C.OS_LOG_TYPE_DEFAULT)
+
+	switch level {
+	case debugLevel:
+		logType = C.OS_LOG_TYPE_DEBUG
+	case errorLevel:
+		logType = C.OS_LOG_TYPE_ERROR
+	}
+
+	cMsg := C.CString(msg)
+	defer C.free(unsafe.Pointer(cMsg))
+
+	C.osLogWrite(logType, cMsg)
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"errors"
+)
+
+// osLog is only supported on macOS.
+type osLog struct{}
+
+func newOSLog(subsystem string) (osLog, error) {
+	return osLog{}, errors.New("the " + osLogTarget + " log target is only supported on macOS")
+}
+
+func (o osLog) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (o osLog) log(level logLevel, msg string) {}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// decodePlist decodes an XML property list. Dictionaries are
+// decoded as map[string]any, arrays as []any, and the remaining
+// types as bool, int64, float64, string, []byte, and time.Time.
+func decodePlist(r io.Reader) (any, error) {
+	decoder := xml.NewDecoder(r)
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find plist element - %w", err)
+		}
+
+		start, isStart := token.(xml.StartElement)
+		if !isStart {
+			continue
+		}
+
+		if start.Name.Local != "plist" {
+			return nil, fmt.Errorf("unexpected root element: %q", start.Name.Local)
+		}
+
+		start, err = nextStartElement(decoder)
+		if err != nil {
+			return nil, err
+		}
+
+		return decodePlistValue(decoder, start)
+	}
+}
+
+// plistLookup returns the value at the specified path in a value
+// returned by decodePlist. Each path element is either a dictionary
+// key or an array index (e.g., "IOConsoleUsers", "0"). It returns
+// false if the value does not exist.
+func plistLookup(v any, path ...string) (any, bool) {
+	for _, elem := range path {
+		switch container := v.(type) {
+		case map[string]any:
+			var ok bool
+
+			v, ok = container[elem]
+			if !ok {
+				return nil, false
+			}
+		case []any:
+			i, err := strconv.Atoi(elem)
+			if err != nil || i < 0 || i >= len(container) {
+				return nil, false
+			}
+
+			v = container[i]
+		default:
+			return nil, false
+		}
+	}
+
+	return v, true
+}
+
+func nextStartElement(decoder *xml.Decoder) (xml.StartElement, error) {
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			return t, nil
+		case xml.EndElement:
+			return xml.StartElement{}, errEndOfElement
+		}
+	}
+}
+
+// errEndOfElement is returned by nextStartElement when the
+// current element ends before another element starts.
+var errEndOfElement = errors.New("end of element")
+
+func decodePlistValue(decoder *xml.Decoder, start xml.StartElement) (any, error) {
+	switch start.Name.Local {
+	case "dict":
+		dict := make(map[string]any)
+
+		for {
+			keyStart, err := nextStartElement(decoder)
+			if errors.Is(err, errEndOfElement) {
+				return dict, nil
+			} else if err != nil {
+				return nil, err
+			}
+
+			if keyStart.Name.Local != "key" {
+				return nil, fmt.Errorf("expected dict key, got: %q", keyStart.Name.Local)
+			}
+
+			key, err := plistText(decoder, keyStart)
+			if err != nil {
+				return nil, err
+			}
+
+			valueStart, err := nextStartElement(decoder)
+			if err != nil {
+				return nil, fmt.Errorf("failed to find value for dict key %q - %w", key, err)
+			}
+
+			dict[key], err = decodePlistValue(decoder, valueStart)
+			if err != nil {
+				return nil, err
+			}
+		}
+	case "array":
+		var array []any
+
+		for {
+			valueStart, err := nextStartElement(decoder)
+			if errors.Is(err, errEndOfElement) {
+				return array, nil
+			} else if err != nil {
+				return nil, err
+			}
+
+			value, err := decodePlistValue(decoder, valueStart)
+			if err != nil {
+				return nil, err
+			}
+
+			array = append(array, value)
+		}
+	case "true", "false":
+		err := decoder.Skip()
+		if err != nil {
+			return nil, err
+		}
+
+		return start.Name.Local == "true", nil
+	}
+
+	text, err := plistText(decoder, start)
+	if err != nil {
+		return nil, err
+	}
+
+	switch start.Name.Local {
+	case "string":
+		return text, nil
+	case "integer":
+		return strconv.ParseInt(strings.TrimSpace(text), 0, 64)
+	case "real":
+		return strconv.ParseFloat(strings.TrimSpace(text), 64)
+	case "data":
+		return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(text), ""))
+	case "date":
+		return time.Parse(time.RFC3339, strings.TrimSpace(text))
+	default:
+		return nil, fmt.Errorf("unsupported plist element: %q", start.Name.Local)
+	}
+}
+
+// plistText returns the text of the element that start opened.
+func plistText(decoder *xml.Decoder, start xml.StartElement) (string, error) {
+	var text string
+
+	err := decoder.DecodeElement(&text, &start)
+	if err != nil {
+		return "", err
+	}
+
+	return text, nil
+}
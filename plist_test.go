@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPlist = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+  <key>IOConsoleUsers</key>
+  <array>
+    <dict>
+      <key>kCGSSessionOnConsoleKey</key>
+      <true/>
+      <key>CGSSessionScreenIsLocked</key>
+      <false/>
+      <key>kCGSSessionUserIDKey</key>
+      <integer>501</integer>
+      <key>kCGSSessionUserNameKey</key>
+      <string>stephen &amp; co</string>
+    </dict>
+  </array>
+  <key>Ratio</key>
+  <real>0.5</real>
+  <key>Hex</key>
+  <integer>0x1f</integer>
+  <key>Data</key>
+  <data>
+    aGVs
+    bG8=
+  </data>
+  <key>Date</key>
+  <date>2024-05-01T09:00:00Z</date>
+  <key>Empty</key>
+  <array/>
+</dict>
+</plist>
+`
+
+func TestDecodePlist(t *testing.T) {
+	v, err := decodePlist(strings.NewReader(testPlist))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{
+		"IOConsoleUsers": []any{
+			map[string]any{
+				"kCGSSessionOnConsoleKey":  true,
+				"CGSSessionScreenIsLocked": false,
+				"kCGSSessionUserIDKey":     int64(501),
+				"kCGSSessionUserNameKey":   "stephen & co",
+			},
+		},
+		"Ratio": 0.5,
+		"Hex":   int64(31),
+		"Data":  []byte("hello"),
+		"Date":  time.Date(2024, time.May, 1, 9, 0, 0, 0, time.UTC),
+		"Empty": []any(nil),
+	}
+
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("got %#v - want %#v", v, want)
+	}
+}
+
+func TestPlistLookup(t *testing.T) {
+	v, err := decodePlist(strings.NewReader(testPlist))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path   []string
+		want   any
+		wantOK bool
+	}{
+		{path: []string{"IOConsoleUsers", "0", "kCGSSessionUserIDKey"}, want: int64(501), wantOK: true},
+		{path: []string{"Ratio"}, want: 0.5, wantOK: true},
+		{path: []string{"IOConsoleUsers", "1"}},
+		{path: []string{"IOConsoleUsers", "-1"}},
+		{path: []string{"IOConsoleUsers", "first"}},
+		{path: []string{"Missing"}},
+		{path: []string{"Ratio", "0"}},
+	}
+
+	for _, test := range tests {
+		got, ok := plistLookup(v, test.path...)
+		if ok != test.wantOK || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %#v, %t - want %#v, %t",
+				test.path, got, ok, test.want, test.wantOK)
+		}
+	}
+}
+
+func TestDecodePlistMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		plist string
+	}{
+		{name: "empty", plist: ""},
+		{name: "not xml", plist: "{}"},
+		{name: "wrong root element", plist: "<dict></dict>"},
+		{name: "empty plist", plist: "<plist></plist>"},
+		{name: "unterminated dict", plist: "<plist><dict><key>a</key><string>b</string>"},
+		{name: "dict value without key", plist: "<plist><dict><string>b</string></dict></plist>"},
+		{name: "dict key without value", plist: "<plist><dict><key>a</key></dict></plist>"},
+		{name: "invalid integer", plist: "<plist><integer>12a</integer></plist>"},
+		{name: "invalid real", plist: "<plist><real>1.2.3</real></plist>"},
+		{name: "invalid data", plist: "<plist><data>!!!</data></plist>"},
+		{name: "invalid date", plist: "<plist><date>yesterday</date></plist>"},
+		{name: "unsupported element", plist: "<plist><set></set></plist>"},
+		{name: "mismatched tags", plist: "<plist><array><string>a</array></plist>"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := decodePlist(bytes.NewReader([]byte(test.plist)))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
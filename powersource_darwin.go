@@ -0,0 +1,45 @@
+package main
+
+/*
+#cgo LDFLAGS: -framework IOKit -framework CoreFoundation
+
+#include <CoreFoundation/CoreFoundation.h>
+#include <IOKit/ps/IOPowerSources.h>
+#include <IOKit/ps/IOPSKeys.h>
+
+// providingPowerSourceIsAC returns 1 if the computer is drawing
+// power from an AC adapter, 0 if it is not (e.g., it is running
+// on battery), and -1 if the power source could not be determined.
+static int providingPowerSourceIsAC() {
+	CFTypeRef info = IOPSCopyPowerSourcesInfo();
+	if (info == NULL) {
+		return -1;
+	}
+
+	int isAC = -1;
+
+	CFStringRef sourceType = IOPSGetProvidingPowerSourceType(info);
+	if (sourceType != NULL) {
+		isAC = CFStringCompare(sourceType, CFSTR(kIOPMACPowerKey), 0) == kCFCompareEqualTo;
+	}
+
+	CFRelease(info);
+
+	return isAC;
+}
+*/
+import "C"
+
+// isOnACPower returns true if the computer is drawing power from
+// an AC adapter. The second return value is false if the power
+// source could not be determined.
+func isOnACPower() (bool, bool) {
+	switch C.providingPowerSourceIsAC() {
+	case -1:
+		return false, false
+	case 0:
+		return false, true
+	default:
+		return true, true
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// isOnACPower returns true if an AC adapter ("Mains" power
+// supply) is online. The second return value is false if the
+// computer has no AC adapter power supply.
+func isOnACPower() (bool, bool) {
+	supplyDirs, _ := filepath.Glob("/sys/class/power_supply/*")
+
+	hasMains := false
+
+	for _, supplyDir := range supplyDirs {
+		supplyType, err := os.ReadFile(filepath.Join(supplyDir, "type"))
+		if err != nil || strings.TrimSpace(string(supplyType)) != "Mains" {
+			continue
+		}
+
+		hasMains = true
+
+		online, err := os.ReadFile(filepath.Join(supplyDir, "online"))
+		if err == nil && strings.TrimSpace(string(online)) == "1" {
+			return true, true
+		}
+	}
+
+	return false, hasMains
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	succeededStatus = "succeeded"
+	failedStatus    = "failed"
+	stoppedStatus   = "stopped"
+	skippedStatus   = "skipped"
+	deferredStatus  = "deferred"
+)
+
+// runSummary describes the results of the programs executed for
+// a single event. It is written to the run hook's stdin.
+type runSummary struct {
+	Event    string      `json:"event"`
+	Time     time.Time   `json:"time"`
+	Programs []exeResult `json:"programs"`
+}
+
+// exeResult is the final status of a program executed for
+// an event.
+type exeResult struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+
+	// ExitCode is the exit status of the program's last
+	// execution. It is nil if the program succeeded or did
+	// not exit normally (e.g., because it was killed).
+	ExitCode *int `json:"exitCode,omitempty"`
+}
+
+func newExeResult(exePath string, err error) exeResult {
+	result := exeResult{
+		Path:   exePath,
+		Status: succeededStatus,
+	}
+
+	switch {
+	case err == nil:
+		return result
+	case errors.Is(err, stoppedErr), errors.Is(err, context.Canceled):
+		result.Status = stoppedStatus
+	case errors.Is(err, deferredErr):
+		result.Status = deferredStatus
+	case errors.Is(err, skippedErr):
+		result.Status = skippedStatus
+	default:
+		result.Status = failedStatus
+	}
+
+	result.Error = err.Error()
+
+	code, hasCode := exitCode(err)
+	if hasCode {
+		result.ExitCode = &code
+	}
+
+	return result
+}
+
+// settleRun reports the run once its programs have exited and
+// then marks it as settled. It does not block.
+func (o *execCtl) settleRun(run *execRun) {
+	go func() {
+		defer close(run.settled)
+
+		run.wg.Wait()
+
+		run.mu.Lock()
+		summary := runSummary{
+			Event:    run.ev.name,
+			Time:     run.ev.time,
+			Programs: append([]exeResult(nil), run.results...),
+		}
+		run.mu.Unlock()
+
+		// Nothing was executed (e.g., because of -dry-run).
+		if len(summary.Programs) == 0 {
+			return
+		}
+
+		summary.log()
+
+		if o.runHook != "" {
+			err := o.execRunHook(summary)
+			if err != nil {
+				errorf("[%s] run hook failed - %s", o.runHook, err)
+			}
+		}
+	}()
+}
+
+// log logs a single line summarizing the results.
+func (o runSummary) log() {
+	counts := make(map[string]int)
+	var notSucceeded []string
+
+	for _, result := range o.Programs {
+		counts[result.Status]++
+
+		switch {
+		case result.Status == succeededStatus:
+		case result.ExitCode != nil:
+			notSucceeded = append(notSucceeded, fmt.Sprintf("%s (%s, exit status %d)",
+				result.Path, result.Status, *result.ExitCode))
+		default:
+			notSucceeded = append(notSucceeded, result.Path+" ("+result.Status+")")
+		}
+	}
+
+	msg := fmt.Sprintf("finished executing programs for %s: %d %s, %d %s, %d %s, %d %s, %d %s",
+		o.Event,
+		counts[succeededStatus], succeededStatus,
+		counts[failedStatus], failedStatus,
+		counts[stoppedStatus], stoppedStatus,
+		counts[skippedStatus], skippedStatus,
+		counts[deferredStatus], deferredStatus)
+
+	if len(notSucceeded) > 0 {
+		msg += " - " + strings.Join(notSucceeded, ", ")
+	}
+
+	infof("%s", msg)
+}
+
+// execRunHook executes o.runHook with the summary written
+// to its stdin as JSON.
+func (o *execCtl) execRunHook(summary runSummary) error {
+	summaryJSON, err := json.Marshal(summary)
+	if err != nil {
+		return fmt.Errorf("failed to encode summary - %w", err)
+	}
+
+	ctx, cancelFn := context.WithTimeout(o.ctx, o.defaults.timeout)
+	defer cancelFn()
+
+	hook := exec.CommandContext(ctx, o.runHook)
+	hook.Env = os.Environ()
+	hook.Stdin = bytes.NewReader(summaryJSON)
+
+	stderr := newExeLogger(o.runHook, "stderr", o.logDir, nil, o.collapseRepeats)
+	defer stderr.Close()
+
+	stdout := newExeLogger(o.runHook, "stdout", o.logDir, nil, o.collapseRepeats)
+	defer stdout.Close()
+
+	hook.Stderr = stderr
+	hook.Stdout = stdout
+
+	err = hook.Run()
+	if err != nil {
+		return fmt.Errorf("exec failed - %w", err)
+	}
+
+	return nil
+}
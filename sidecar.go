@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	sidecarExt       = ".json"
+	timeoutInNameStr = "-timeout-"
+
+	defaultRetryInterval = 10 * time.Second
+)
+
+// exeInfo describes an executable and how it should be executed.
+type exeInfo struct {
+	path          string
+	timeout       time.Duration
+	retryInterval time.Duration
+	maxRetries    int
+	minInterval   time.Duration
+	needsUnlock   bool
+	needsAC       bool
+	needsNetwork  bool
+
+	// interpreter, if non-empty, is the program and
+	// arguments used to execute the executable.
+	interpreter []string
+
+	// args are passed to the executable.
+	args []string
+
+	// waitFor are the addresses (in the format "host:port")
+	// that must accept TCP connections before the executable
+	// is executed.
+	waitFor []string
+
+	// window, if non-nil, is the time of day during which
+	// the executable may be executed.
+	window *timeWindow
+
+	// dependsOn are the names of the executables that must
+	// succeed before the executable is executed.
+	dependsOn []string
+
+	// runOnce is true if the executable should no longer be
+	// executed once it succeeds.
+	runOnce bool
+
+	// killSignal is sent to the executable when it is stopped.
+	killSignal syscall.Signal
+
+	// warnOnTimeout is true if a warning is logged when the
+	// executable times-out rather than stopping it.
+	warnOnTimeout bool
+
+	// group is the name of the group of executables of which
+	// only one may be executing at a time.
+	group string
+
+	// priority orders the executable relative to the others.
+	// Lower priorities are executed first.
+	priority int
+
+	// sequentialDir, if non-empty, is the directory whose
+	// executables are executed one at a time in order.
+	sequentialDir string
+}
+
+// newExeInfo returns the exeInfo for exePath using the settings
+// in defaults, overridden by dirDefaults (if non-nil) and then by
+// the timeout in the executable's name. Each non-nil override
+// overrides those and the overrides before it. If a sidecar
+// configuration file exists for the executable, it is parsed
+// and used to override them all.
+func newExeInfo(exePath string, defaults exeInfo, dirDefaults *exeConfig, overrides ...*exeConfig) (*exeInfo, error) {
+	info := &defaults
+	info.path = exePath
+	info.needsUnlock = strings.Contains(filepath.Base(exePath), needsUnlockStr)
+	info.needsAC = strings.Contains(filepath.Base(exePath), needsACStr)
+	info.needsNetwork = strings.Contains(filepath.Base(exePath), needsNetworkStr)
+
+	if dirDefaults != nil {
+		info.apply(dirDefaults)
+	}
+
+	timeout, hasTimeout := timeoutFromName(filepath.Base(exePath))
+	if hasTimeout {
+		info.timeout = timeout
+	}
+
+	for _, override := range overrides {
+		if override != nil {
+			info.apply(override)
+		}
+	}
+
+	config, err := readSidecar(sidecarPath(exePath))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return info, nil
+	case err != nil:
+		return nil, err
+	}
+
+	info.apply(config)
+
+	return info, nil
+}
+
+// apply overrides the exeInfo's settings with those
+// specified in config.
+func (o *exeInfo) apply(config *exeConfig) {
+	if config.Timeout > 0 {
+		o.timeout = time.Duration(config.Timeout)
+	}
+
+	if config.RetryInterval > 0 {
+		o.retryInterval = time.Duration(config.RetryInterval)
+	}
+
+	if config.MaxRetries != nil {
+		o.maxRetries = *config.MaxRetries
+	}
+
+	if config.RunOnUnlock {
+		o.needsUnlock = true
+	}
+
+	if config.RunOnAC {
+		o.needsAC = true
+	}
+
+	if config.RequiresNetwork {
+		o.needsNetwork = true
+	}
+
+	if len(config.WaitFor) > 0 {
+		o.waitFor = append(slices.Clone(o.waitFor), config.WaitFor...)
+	}
+
+	if config.RunBetween != nil {
+		o.window = config.RunBetween
+	}
+
+	if config.MinInterval > 0 {
+		o.minInterval = time.Duration(config.MinInterval)
+	}
+
+	if len(config.DependsOn) > 0 {
+		o.dependsOn = config.DependsOn
+	}
+
+	if config.RunOnce {
+		o.runOnce = true
+	}
+
+	if config.Group != "" {
+		o.group = config.Group
+	}
+
+	if config.Priority != nil {
+		o.priority = *config.Priority
+	}
+
+	if config.KillSignal != "" {
+		// The signal is validated by exeConfig.validate.
+		o.killSignal, _ = parseSignal(config.KillSignal)
+	}
+}
+
+// timeoutInNameRe matches timeoutInNameStr followed by a
+// duration in the format accepted by time.ParseDuration
+// (e.g., "backup-timeout-1h30m.sh").
+var timeoutInNameRe = regexp.MustCompile(regexp.QuoteMeta(timeoutInNameStr) +
+	`((?:[0-9]+(?:ns|us|µs|ms|s|m|h))+)`)
+
+// timeoutFromName parses the timeout in an executable's name.
+// It returns false if the name does not specify a timeout.
+func timeoutFromName(name string) (time.Duration, bool) {
+	match := timeoutInNameRe.FindStringSubmatch(name)
+	if match == nil {
+		return 0, false
+	}
+
+	timeout, err := time.ParseDuration(match[1])
+	if err != nil || timeout <= 0 {
+		return 0, false
+	}
+
+	return timeout, true
+}
+
+// command returns the program name and arguments used to
+// execute the executable.
+func (o *exeInfo) command() (string, []string) {
+	if len(o.interpreter) == 0 {
+		return o.path, slices.Clone(o.args)
+	}
+
+	args := append(slices.Clone(o.interpreter[1:]), o.path)
+	args = append(args, o.args...)
+
+	return o.interpreter[0], args
+}
+
+// sidecarPath returns the path to exePath's sidecar configuration
+// file. E.g., the sidecar for "foo.sh" is "foo.sh.json".
+func sidecarPath(exePath string) string {
+	return exePath + sidecarExt
+}
+
+func isSidecar(name string) bool {
+	return strings.HasSuffix(name, sidecarExt)
+}
+
+// exeConfig is the format of an executable's sidecar
+// configuration file.
+type exeConfig struct {
+	// Timeout is the maximum amount of time the executable
+	// may run for.
+	Timeout duration `json:"timeout"`
+
+	// RetryInterval is the amount of time to wait before
+	// re-executing the executable after it fails.
+	RetryInterval duration `json:"retryInterval"`
+
+	// MaxRetries is the number of times the executable is
+	// re-executed after it fails. Zero means unlimited.
+	MaxRetries *int `json:"maxRetries"`
+
+	// RunOnUnlock is equivalent to the executable's name
+	// containing needsUnlockStr when set to true.
+	RunOnUnlock bool `json:"runOnUnlock"`
+
+	// RunOnAC is equivalent to the executable's name containing
+	// needsACStr when set to true.
+	RunOnAC bool `json:"runOnAC"`
+
+	// RequiresNetwork is equivalent to the executable's name
+	// containing needsNetworkStr when set to true.
+	RequiresNetwork bool `json:"requiresNetwork"`
+
+	// WaitFor are addresses (in the format "host:port") that
+	// must accept TCP connections before the executable is
+	// executed. They are in addition to the defaults.
+	WaitFor []string `json:"waitFor"`
+
+	// RunBetween is the time of day during which the executable
+	// may be executed (e.g., "01:00-05:00"). The executable is
+	// skipped for events that occur outside of it.
+	RunBetween *timeWindow `json:"runBetween"`
+
+	// MinInterval is the amount of time after the executable
+	// succeeds during which it is skipped.
+	MinInterval duration `json:"minInterval"`
+
+	// DependsOn are the names of the executables (e.g.,
+	// "mount.sh") that must succeed before the executable
+	// is executed for the same event.
+	DependsOn []string `json:"dependsOn"`
+
+	// RunOnce, when set to true, causes the executable to be
+	// skipped for all events once it succeeds.
+	RunOnce bool `json:"runOnce"`
+
+	// KillSignal is the name or number of the signal sent to
+	// the executable when it is stopped (e.g., "SIGUSR1").
+	KillSignal string `json:"killSignal"`
+
+	// Group is the name of a group of executables of which
+	// only one may be executing at a time (e.g., "sync").
+	Group string `json:"group"`
+
+	// Priority orders the executable relative to the others
+	// regardless of its name. Lower priorities are executed
+	// first, and executables with the same priority are
+	// ordered by name. Defaults to zero.
+	Priority *int `json:"priority"`
+}
+
+func readSidecar(filePath string) (*exeConfig, error) {
+	raw, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var config exeConfig
+
+	// Otherwise, a misspelled setting would be silently
+	// ignored.
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+
+	err = decoder.Decode(&config)
+	if err == nil && decoder.More() {
+		err = errors.New("unexpected data after the JSON object")
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse sidecar file %q - %w",
+			filePath, err)
+	}
+
+	err = config.validate()
+	if err != nil {
+		return nil, fmt.Errorf("sidecar file %q: %w", filePath, err)
+	}
+
+	return &config, nil
+}
+
+func (o *exeConfig) validate() error {
+	if o.MaxRetries != nil && *o.MaxRetries < 0 {
+		return errors.New("maxRetries cannot be negative")
+	}
+
+	if o.KillSignal != "" {
+		_, err := parseSignal(o.KillSignal)
+		if err != nil {
+			return fmt.Errorf("invalid killSignal - %w", err)
+		}
+	}
+
+	for _, name := range o.DependsOn {
+		if name == "" || filepath.Base(name) != name {
+			return fmt.Errorf("invalid dependsOn name %q - it must be a file name", name)
+		}
+	}
+
+	for _, addr := range o.WaitFor {
+		_, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return fmt.Errorf("invalid waitFor address - %w", err)
+		}
+	}
+
+	return nil
+}
+
+// duration is a time.Duration that is represented in JSON and
+// TOML as a string parsable by time.ParseDuration (e.g., "30s").
+type duration time.Duration
+
+func (o *duration) UnmarshalJSON(b []byte) error {
+	var str string
+
+	err := json.Unmarshal(b, &str)
+	if err != nil {
+		return fmt.Errorf("duration must be a string - %w", err)
+	}
+
+	return o.UnmarshalText([]byte(str))
+}
+
+func (o *duration) UnmarshalText(b []byte) error {
+	d, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+
+	*o = duration(d)
+
+	return nil
+}
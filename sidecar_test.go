@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadSidecar(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr string
+	}{
+		{name: "valid", json: `{"timeout": "1h", "maxRetries": 2}`},
+		{name: "unknown field", json: `{"timout": "1h"}`, wantErr: `unknown field "timout"`},
+		{name: "trailing data", json: `{"timeout": "1h"} {}`, wantErr: "unexpected data"},
+		{name: "invalid value", json: `{"maxRetries": -1}`, wantErr: "cannot be negative"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), "program.sh"+sidecarExt)
+
+			err := os.WriteFile(filePath, []byte(test.json), 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			config, err := readSidecar(filePath)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("got error %v - want error containing %q", err, test.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if time.Duration(config.Timeout) != time.Hour {
+				t.Fatalf("got timeout %v - want 1h", config.Timeout)
+			}
+		})
+	}
+}
+
+func TestNewExeInfoTimeoutPrecedence(t *testing.T) {
+	dirDefaults := &exeConfig{Timeout: duration(5 * time.Minute)}
+
+	tests := []struct {
+		name          string
+		exeName       string
+		dirDefaults   *exeConfig
+		programConfig *exeConfig
+		sidecar       string
+		want          time.Duration
+	}{
+		{name: "defaults", exeName: "backup.sh", want: time.Minute},
+		{name: "dir config", exeName: "backup.sh", dirDefaults: dirDefaults, want: 5 * time.Minute},
+		{name: "name overrides dir config", exeName: "backup-timeout-1h.sh", dirDefaults: dirDefaults, want: time.Hour},
+		{
+			name:          "program config overrides name",
+			exeName:       "backup-timeout-1h.sh",
+			dirDefaults:   dirDefaults,
+			programConfig: &exeConfig{Timeout: duration(2 * time.Hour)},
+			want:          2 * time.Hour,
+		},
+		{
+			name:        "sidecar overrides name",
+			exeName:     "backup-timeout-1h.sh",
+			dirDefaults: dirDefaults,
+			sidecar:     `{"timeout": "3h"}`,
+			want:        3 * time.Hour,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exePath := filepath.Join(t.TempDir(), test.exeName)
+
+			if test.sidecar != "" {
+				err := os.WriteFile(sidecarPath(exePath), []byte(test.sidecar), 0o600)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			info, err := newExeInfo(exePath, exeInfo{timeout: time.Minute}, test.dirDefaults, test.programConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if info.timeout != test.want {
+				t.Fatalf("got timeout %v - want %v", info.timeout, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// signalsByName maps signal names to signals.
+var signalsByName = map[string]syscall.Signal{
+	"SIGHUP":   syscall.SIGHUP,
+	"SIGINT":   syscall.SIGINT,
+	"SIGQUIT":  syscall.SIGQUIT,
+	"SIGKILL":  syscall.SIGKILL,
+	"SIGUSR1":  syscall.SIGUSR1,
+	"SIGUSR2":  syscall.SIGUSR2,
+	"SIGALRM":  syscall.SIGALRM,
+	"SIGTERM":  syscall.SIGTERM,
+	"SIGCONT":  syscall.SIGCONT,
+	"SIGWINCH": syscall.SIGWINCH,
+}
+
+// notifyStopSignals returns a context that is cancelled when
+// SIGINT, SIGTERM, or SIGQUIT is received, which makes the caller
+// shut down gracefully. SIGQUIT also logs the stack of each
+// goroutine first (like the Go runtime does by default) to help
+// diagnose hangs.
+func notifyStopSignals(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancelFn := context.WithCancelCause(parent)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-sigs:
+			if sig == syscall.SIGQUIT {
+				dumpStacks()
+			}
+
+			cancelFn(fmt.Errorf("received %s", signalName(sig.(syscall.Signal))))
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(sigs)
+		cancelFn(context.Canceled)
+	}
+}
+
+// dumpStacks logs the stack of each goroutine.
+func dumpStacks() {
+	errorf("received SIGQUIT, dumping goroutine stacks")
+
+	// A debug value of 2 uses the same format as the
+	// Go runtime's tracebacks.
+	err := pprof.Lookup("goroutine").WriteTo(log.Writer(), 2)
+	if err != nil {
+		warnf("failed to dump goroutine stacks - %s", err)
+	}
+}
+
+// signalName returns the name of sig (e.g., "SIGTERM") or its
+// description if its name is unknown.
+func signalName(sig syscall.Signal) string {
+	for name, s := range signalsByName {
+		if s == sig {
+			return name
+		}
+	}
+
+	return sig.String()
+}
+
+// parseSignal parses a signal name (e.g., "SIGHUP" or "hup")
+// or number (e.g., "1").
+func parseSignal(s string) (syscall.Signal, error) {
+	num, err := strconv.Atoi(s)
+	if err == nil {
+		if num <= 0 {
+			return 0, fmt.Errorf("invalid signal number: %d", num)
+		}
+
+		return syscall.Signal(num), nil
+	}
+
+	name := strings.ToUpper(s)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+
+	sig, ok := signalsByName[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown signal: %q", s)
+	}
+
+	return sig, nil
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// stateFileName is the name of the file in the state directory
+// that persists the programs' state across restarts.
+const stateFileName = "state.json"
+
+// defaultStateDir returns the default value of -state-dir. It
+// returns an empty string if the current user does not have a
+// config directory (e.g., because $HOME is not set).
+func defaultStateDir() string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(configDir, appName)
+}
+
+// stateFile persists the state of programs in a JSON file. An
+// empty path keeps the state in memory only.
+type stateFile struct {
+	path     string
+	mu       sync.Mutex
+	programs map[string]*programState
+}
+
+// programState is the persisted state of a program, which is
+// identified by its path.
+type programState struct {
+	// LastRun is the time the program was last executed.
+	LastRun time.Time `json:"lastRun"`
+
+	// LastDurationSeconds is the amount of time the program's
+	// last execution ran for.
+	LastDurationSeconds float64 `json:"lastDurationSeconds"`
+
+	// LastStatus is the status of the program's last execution
+	// (e.g., succeededStatus).
+	LastStatus string `json:"lastStatus,omitempty"`
+
+	// LastError describes why the program's last execution did
+	// not succeed.
+	LastError string `json:"lastError,omitempty"`
+
+	// LastSucceeded is the time the program last succeeded.
+	LastSucceeded *time.Time `json:"lastSucceeded,omitempty"`
+
+	// RanOnce is the time a runOnce program succeeded.
+	RanOnce *time.Time `json:"ranOnce,omitempty"`
+
+	// RanOnceHash is the SHA-256 hash of the runOnce program
+	// when it succeeded. A program whose contents change is
+	// executed again.
+	RanOnceHash string `json:"ranOnceHash,omitempty"`
+}
+
+// loadStateFile reads the state file at filePath. A state file
+// that does not exist yet is treated as empty.
+func loadStateFile(filePath string) (*stateFile, error) {
+	state := &stateFile{
+		path:     filePath,
+		programs: make(map[string]*programState),
+	}
+
+	if filePath == "" {
+		return state, nil
+	}
+
+	raw, err := os.ReadFile(filePath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return state, nil
+	case err != nil:
+		return nil, err
+	}
+
+	err = json.Unmarshal(raw, &state.programs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse state file %q - %w", filePath, err)
+	}
+
+	return state, nil
+}
+
+// lastSuccesses returns the times the programs last succeeded,
+// mapped by their paths.
+func (o *stateFile) lastSuccesses() map[string]time.Time {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	successes := make(map[string]time.Time)
+
+	for exePath, state := range o.programs {
+		if state.LastSucceeded != nil {
+			successes[exePath] = *state.LastSucceeded
+		}
+	}
+
+	return successes
+}
+
+// all returns a copy of the state of each program, mapped by
+// their paths.
+func (o *stateFile) all() map[string]programState {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	programs := make(map[string]programState, len(o.programs))
+
+	for exePath, state := range o.programs {
+		programs[exePath] = *state
+	}
+
+	return programs
+}
+
+// get returns a copy of the program's state.
+func (o *stateFile) get(exePath string) programState {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	state, ok := o.programs[exePath]
+	if !ok {
+		return programState{}
+	}
+
+	return *state
+}
+
+// update calls fn with the program's state and then writes
+// the state file.
+func (o *stateFile) update(exePath string, fn func(*programState)) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	state, ok := o.programs[exePath]
+	if !ok {
+		state = &programState{}
+		o.programs[exePath] = state
+	}
+
+	fn(state)
+
+	if o.path == "" {
+		return nil
+	}
+
+	raw, err := json.MarshalIndent(o.programs, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(o.path), 0o755)
+	if err != nil {
+		return err
+	}
+
+	// Writing to a temporary file and renaming it prevents
+	// the state file from being truncated by a crash.
+	tmpPath := o.path + ".tmp"
+
+	err = os.WriteFile(tmpPath, raw, 0o644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, o.path)
+}
+
+// hashFile returns the hex-encoded SHA-256 hash of the file
+// at filePath.
+func hashFile(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+
+	_, err = io.Copy(hash, f)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// ranOnce returns the time the runOnce program at exePath
+// succeeded. It returns false if the program has not succeeded
+// yet or if it has changed since.
+func (o *execCtl) ranOnce(exePath string) (time.Time, bool) {
+	state := o.state.get(exePath)
+	if state.RanOnce == nil {
+		return time.Time{}, false
+	}
+
+	hash, err := hashFile(exePath)
+	if err != nil {
+		warnf("[%s] failed to hash file - %s", exePath, err)
+
+		return time.Time{}, false
+	}
+
+	return *state.RanOnce, hash == state.RanOnceHash
+}
+
+// recordRun records the result of executing the program, which
+// was started at the specified time. err is the error returned
+// by execOnce.
+func (o *execCtl) recordRun(exe *exeInfo, started time.Time, err error) {
+	result := newExeResult(exe.path, err)
+
+	if err == nil {
+		o.setSucceeded(exe.path)
+	}
+
+	var hash string
+
+	if err == nil && exe.runOnce {
+		var hashErr error
+
+		hash, hashErr = hashFile(exe.path)
+		if hashErr != nil {
+			warnf("[%s] failed to hash file, it will be executed again - %s",
+				exe.path, hashErr)
+		}
+	}
+
+	now := o.clock.Now()
+
+	updateErr := o.state.update(exe.path, func(state *programState) {
+		state.LastRun = started
+		state.LastDurationSeconds = now.Sub(started).Seconds()
+		state.LastStatus = result.Status
+		state.LastError = result.Error
+
+		if err == nil {
+			state.LastSucceeded = &now
+		}
+
+		if hash != "" {
+			state.RanOnce = &now
+			state.RanOnceHash = hash
+		}
+	})
+	if updateErr != nil {
+		warnf("[%s] failed to write state file - %s", exe.path, updateErr)
+	}
+}
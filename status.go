@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// defaultRuntimeFilePath returns the path of a file used by a
+// running instance of the program for the current user with the
+// specified extension (e.g., ".sock").
+func defaultRuntimeFilePath(ext string) string {
+	return filepath.Join("/tmp", appName+"-"+strconv.Itoa(os.Getuid())+ext)
+}
+
+// status is the response sent to status clients.
+type status struct {
+	Events   []eventStatus   `json:"events"`
+	Children []childStatus   `json:"children"`
+	Programs []programStatus `json:"programs"`
+}
+
+// eventStatus describes the last time an event was received.
+type eventStatus struct {
+	Name string    `json:"name"`
+	Time time.Time `json:"time"`
+}
+
+// childStatus describes a program whose execRetry loop is active.
+type childStatus struct {
+	Path    string    `json:"path"`
+	Attempt int       `json:"attempt"`
+	PID     int       `json:"pid,omitempty"`
+	Started time.Time `json:"started,omitempty"`
+}
+
+// programStatus describes a program's last execution.
+type programStatus struct {
+	Path          string     `json:"path"`
+	LastRun       time.Time  `json:"lastRun"`
+	LastStatus    string     `json:"lastStatus,omitempty"`
+	LastSucceeded *time.Time `json:"lastSucceeded,omitempty"`
+}
+
+// setLastEvent records that ev was received.
+func (o *execCtl) setLastEvent(ev event) {
+	o.lastEventsMu.Lock()
+	defer o.lastEventsMu.Unlock()
+
+	if o.lastEvents == nil {
+		o.lastEvents = make(map[string]time.Time)
+	}
+
+	o.lastEvents[ev.name] = ev.time
+}
+
+// status returns the current status of the execCtl.
+func (o *execCtl) status() status {
+	s := status{
+		Events:   []eventStatus{},
+		Children: []childStatus{},
+		Programs: []programStatus{},
+	}
+
+	o.lastEventsMu.Lock()
+
+	for name, t := range o.lastEvents {
+		s.Events = append(s.Events, eventStatus{
+			Name: name,
+			Time: t,
+		})
+	}
+
+	o.lastEventsMu.Unlock()
+
+	slices.SortFunc(s.Events, func(a eventStatus, b eventStatus) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	if o.state != nil {
+		for exePath, state := range o.state.all() {
+			s.Programs = append(s.Programs, programStatus{
+				Path:          exePath,
+				LastRun:       state.LastRun,
+				LastStatus:    state.LastStatus,
+				LastSucceeded: state.LastSucceeded,
+			})
+		}
+
+		slices.SortFunc(s.Programs, func(a programStatus, b programStatus) int {
+			return strings.Compare(a.Path, b.Path)
+		})
+	}
+
+	o.childrenMu.Lock()
+	defer o.childrenMu.Unlock()
+
+	for _, c := range o.children {
+		cs := childStatus{
+			Path:    c.exePath,
+			Attempt: c.attempt,
+		}
+
+		if c.cmd != nil {
+			cs.PID = c.cmd.Process.Pid
+			cs.Started = c.started
+		}
+
+		s.Children = append(s.Children, cs)
+	}
+
+	return s
+}
+
+// serveStatus listens for status clients on a Unix domain socket
+// at socketPath. The socket is removed when the returned
+// listener is closed.
+func (o *execCtl) serveStatus(socketPath string) (net.Listener, error) {
+	// A socket file may have been left behind by an instance
+	// that did not exit cleanly.
+	conn, err := net.Dial("unix", socketPath)
+	if err == nil {
+		conn.Close()
+
+		return nil, fmt.Errorf("status socket %q is already in use by another instance",
+			socketPath)
+	}
+
+	err = os.Remove(socketPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove stale status socket - %w", err)
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on status socket - %w", err)
+	}
+
+	err = os.Chmod(socketPath, 0o600)
+	if err != nil {
+		listener.Close()
+
+		return nil, fmt.Errorf("failed to set status socket permissions - %w", err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					warnf("failed to accept status client - %s", err)
+				}
+
+				return
+			}
+
+			go o.writeStatus(conn)
+		}
+	}()
+
+	return listener, nil
+}
+
+func (o *execCtl) writeStatus(conn net.Conn) {
+	defer conn.Close()
+
+	_ = conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+
+	err := json.NewEncoder(conn).Encode(o.status())
+	if err != nil {
+		warnf("failed to write status - %s", err)
+	}
+}
+
+// printStatus connects to the status socket at socketPath and
+// writes the status of the running instance to w.
+func printStatus(socketPath string, w io.Writer) error {
+	conn, err := net.DialTimeout("unix", socketPath, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to connect to status socket (is %s running?) - %w",
+			appName, err)
+	}
+	defer conn.Close()
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var s status
+
+	err = json.NewDecoder(conn).Decode(&s)
+	if err != nil {
+		return fmt.Errorf("failed to read status - %w", err)
+	}
+
+	// Knowing whether events are being received at all
+	// distinguishes a computer that never woke from
+	// programs that failed.
+	if len(s.Events) == 0 {
+		fmt.Fprint(w, "no events have been received\n\n")
+	} else {
+		table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+		fmt.Fprintln(table, "EVENT\tLAST RECEIVED\tAGO")
+
+		for _, e := range s.Events {
+			fmt.Fprintf(table, "%s\t%s\t%s\n",
+				e.Name, e.Time.Format(time.DateTime), time.Since(e.Time).Round(time.Second))
+		}
+
+		err = table.Flush()
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(w)
+	}
+
+	if len(s.Children) == 0 {
+		fmt.Fprint(w, "no programs are running\n")
+	} else {
+		table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+		fmt.Fprintln(table, "PROGRAM\tPID\tSTARTED\tATTEMPT")
+
+		for _, c := range s.Children {
+			pid := "-"
+			started := "waiting to retry"
+
+			if c.PID != 0 {
+				pid = strconv.Itoa(c.PID)
+				started = c.Started.Format(time.DateTime)
+			}
+
+			fmt.Fprintf(table, "%s\t%s\t%s\t%d\n", c.Path, pid, started, c.Attempt)
+		}
+
+		err = table.Flush()
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(s.Programs) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(w)
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(table, "PROGRAM\tLAST RUN\tSTATUS\tLAST SUCCEEDED")
+
+	for _, p := range s.Programs {
+		lastSucceeded := "never"
+		if p.LastSucceeded != nil {
+			lastSucceeded = p.LastSucceeded.Format(time.DateTime)
+		}
+
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n",
+			p.Path, p.LastRun.Format(time.DateTime), p.LastStatus, lastSucceeded)
+	}
+
+	return table.Flush()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+)
+
+const webhookTimeout = 10 * time.Second
+
+// webhookPayload is the JSON body POSTed to o.webhook when
+// a program finishes.
+type webhookPayload struct {
+	Event           string  `json:"event"`
+	Name            string  `json:"name"`
+	Path            string  `json:"path"`
+	Status          string  `json:"status"`
+	Error           string  `json:"error,omitempty"`
+	ExitCode        *int    `json:"exitCode,omitempty"`
+	Attempts        int     `json:"attempts"`
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// postWebhook POSTs the result of executing the program to
+// o.webhook. started is the time the program's first attempt
+// started and err is the error returned by its last attempt.
+// It is a no-op unless o.webhook is non-empty. It does not
+// block.
+func (o *execCtl) postWebhook(ev event, exe *exeInfo, attempts int, started time.Time, err error) {
+	if o.webhook == "" {
+		return
+	}
+
+	result := newExeResult(exe.path, err)
+
+	payload := webhookPayload{
+		Event:           ev.name,
+		Name:            filepath.Base(exe.path),
+		Path:            exe.path,
+		Status:          result.Status,
+		Error:           result.Error,
+		ExitCode:        result.ExitCode,
+		Attempts:        attempts,
+		DurationSeconds: o.clock.Now().Sub(started).Seconds(),
+	}
+
+	o.webhookWg.Add(1)
+
+	go func() {
+		defer o.webhookWg.Done()
+
+		err := postJSON(o.webhook, payload)
+		if err != nil {
+			warnf("[%s] failed to post to webhook - %s", exe.path, err)
+		}
+	}()
+}
+
+// postJSON POSTs v to url as JSON.
+func postJSON(url string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload - %w", err)
+	}
+
+	// The request is not tied to o.ctx so that the results
+	// of programs stopped during shutdown are still posted.
+	ctx, cancelFn := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancelFn()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("server responded with %s", resp.Status)
+	}
+
+	return nil
+}
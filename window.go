@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timeWindow is a daily range of local times (e.g., "01:00-05:00").
+// The range wraps around midnight if its end is before its start
+// (e.g., "22:00-02:00").
+type timeWindow struct {
+	// start and end are the amount of time since midnight.
+	start time.Duration
+	end   time.Duration
+}
+
+// parseTimeWindow parses a string in the format "HH:MM-HH:MM".
+func parseTimeWindow(str string) (timeWindow, error) {
+	startStr, endStr, found := strings.Cut(str, "-")
+	if !found {
+		return timeWindow{}, fmt.Errorf("time window must be in the format HH:MM-HH:MM (got: %q)",
+			str)
+	}
+
+	start, err := parseTimeOfDay(startStr)
+	if err != nil {
+		return timeWindow{}, fmt.Errorf("failed to parse time window start - %w", err)
+	}
+
+	end, err := parseTimeOfDay(endStr)
+	if err != nil {
+		return timeWindow{}, fmt.Errorf("failed to parse time window end - %w", err)
+	}
+
+	if start == end {
+		return timeWindow{}, fmt.Errorf("time window start and end cannot be equal (%q)", str)
+	}
+
+	return timeWindow{
+		start: start,
+		end:   end,
+	}, nil
+}
+
+// parseTimeOfDay parses a string in the format "HH:MM" and returns
+// the amount of time since midnight.
+func parseTimeOfDay(str string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(str))
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// contains returns true if t's local time of day is within the
+// window. The start of the window is inclusive, and the end is
+// exclusive.
+func (o timeWindow) contains(t time.Time) bool {
+	t = t.Local()
+
+	sinceMidnight := time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+
+	if o.start < o.end {
+		return sinceMidnight >= o.start && sinceMidnight < o.end
+	}
+
+	return sinceMidnight >= o.start || sinceMidnight < o.end
+}
+
+func (o timeWindow) String() string {
+	return formatTimeOfDay(o.start) + "-" + formatTimeOfDay(o.end)
+}
+
+func formatTimeOfDay(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
+
+func (o *timeWindow) UnmarshalJSON(b []byte) error {
+	var str string
+
+	err := json.Unmarshal(b, &str)
+	if err != nil {
+		return fmt.Errorf("time window must be a string - %w", err)
+	}
+
+	return o.UnmarshalText([]byte(str))
+}
+
+func (o *timeWindow) UnmarshalText(b []byte) error {
+	var err error
+
+	*o, err = parseTimeWindow(string(b))
+
+	return err
+}